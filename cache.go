@@ -0,0 +1,228 @@
+package calendar
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// eventCacheFile holds a calendar's parsed events next to its .ics files.
+const eventCacheFile = "events.cache.json"
+
+// eventCacheVersion is bumped whenever the cache layout or the way events
+// are parsed changes, so older caches are ignored.
+const eventCacheVersion = 3
+
+// EventCache is implemented by stores that can keep a calendar's parsed
+// events alongside the raw data, so reads can skip parsing every file.
+type EventCache interface {
+	// EventsFingerprint returns a value that changes whenever the
+	// calendar's stored events do.
+	EventsFingerprint(calName string) (string, error)
+	// LoadEventCache returns the cached data, or nil if there is none.
+	LoadEventCache(calName string) ([]byte, error)
+	// SaveEventCache replaces the cached data.
+	SaveEventCache(calName string, data []byte) error
+}
+
+// EventsFingerprint hashes the name, size and modification time of every
+// .ics file in the calendar's directory.
+func (s *FSStore) EventsFingerprint(calName string) (string, error) {
+	entries, err := os.ReadDir(s.Config.CalendarDir(calName))
+	if err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	h := sha256.New()
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".ics") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00%d\n", entry.Name(), info.Size(), info.ModTime().UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// LoadEventCache reads events.cache.json from the calendar's directory.
+func (s *FSStore) LoadEventCache(calName string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(s.Config.CalendarDir(calName), eventCacheFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// SaveEventCache writes events.cache.json to the calendar's directory.
+func (s *FSStore) SaveEventCache(calName string, data []byte) error {
+	dir := s.Config.CalendarDir(calName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, eventCacheFile), data, 0644)
+}
+
+// eventCache is the JSON layout of events.cache.json. Floating times are
+// parsed in the configured zone, so the cache is only valid for the
+// Location it was built with.
+type eventCache struct {
+	Version     int           `json:"version"`
+	Fingerprint string        `json:"fingerprint"`
+	Location    string        `json:"location"`
+	Events      []cachedEvent `json:"events"`
+}
+
+// cachedEvent is an Event plus what JSON would otherwise lose: the zone of
+// each time, which recurrence expansion and all-day dates depend on, and
+// the unexported EXDATE and RDATE values.
+type cachedEvent struct {
+	Event
+	StartZone        string       `json:",omitempty"`
+	EndZone          string       `json:",omitempty"`
+	RecurrenceIDZone string       `json:",omitempty"`
+	ExDates          []cachedDate `json:",omitempty"`
+	RDates           []cachedDate `json:",omitempty"`
+}
+
+type cachedDate struct {
+	T      time.Time
+	Zone   string
+	AllDay bool `json:",omitempty"`
+}
+
+// cachedEvents reads calName's parsed events from the store's cache. It
+// reports false when the store has no cache, or the cache is missing,
+// stale or unreadable, in which case the caller should parse the files.
+func (m *CalendarManager) cachedEvents(calName string) ([]Event, bool) {
+	ec, ok := m.Store.(EventCache)
+	if !ok || !m.Config.CacheEvents {
+		return nil, false
+	}
+	data, err := ec.LoadEventCache(calName)
+	if err != nil || data == nil {
+		return nil, false
+	}
+	var cache eventCache
+	if err := json.Unmarshal(data, &cache); err != nil {
+		m.Log.Debugf("ignoring corrupt event cache for %s: %v\n", calName, err)
+		return nil, false
+	}
+	if cache.Version != eventCacheVersion || cache.Location != m.Config.Location().String() {
+		return nil, false
+	}
+	fingerprint, err := ec.EventsFingerprint(calName)
+	if err != nil || fingerprint != cache.Fingerprint {
+		return nil, false
+	}
+
+	events := make([]Event, 0, len(cache.Events))
+	for _, ce := range cache.Events {
+		e := ce.Event
+		if e.Start, ok = inZone(e.Start, ce.StartZone); !ok {
+			return nil, false
+		}
+		if e.End, ok = inZone(e.End, ce.EndZone); !ok {
+			return nil, false
+		}
+		if e.RecurrenceID, ok = inZone(e.RecurrenceID, ce.RecurrenceIDZone); !ok {
+			return nil, false
+		}
+		if e.exdates, ok = fromCachedDates(ce.ExDates); !ok {
+			return nil, false
+		}
+		if e.rdates, ok = fromCachedDates(ce.RDates); !ok {
+			return nil, false
+		}
+		e.Calendar = calName
+		events = append(events, e)
+	}
+	return events, true
+}
+
+// saveEventCache parses calName's stored events and writes them to the
+// store's cache, if it has one and caching is enabled.
+func (m *CalendarManager) saveEventCache(calName string) error {
+	ec, ok := m.Store.(EventCache)
+	if !ok || !m.Config.CacheEvents {
+		return nil
+	}
+	// Fingerprint first: if a file changes while parsing, the cache is
+	// stale on the next read rather than wrongly fresh.
+	fingerprint, err := ec.EventsFingerprint(calName)
+	if err != nil {
+		return err
+	}
+	events, err := m.parseCalendarEvents(calName)
+	if err != nil {
+		return err
+	}
+	cache := eventCache{
+		Version:     eventCacheVersion,
+		Fingerprint: fingerprint,
+		Location:    m.Config.Location().String(),
+		Events:      make([]cachedEvent, len(events)),
+	}
+	for i, e := range events {
+		cache.Events[i] = cachedEvent{
+			Event:            e,
+			StartZone:        zoneName(e.Start),
+			EndZone:          zoneName(e.End),
+			RecurrenceIDZone: zoneName(e.RecurrenceID),
+			ExDates:          toCachedDates(e.exdates),
+			RDates:           toCachedDates(e.rdates),
+		}
+	}
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	return ec.SaveEventCache(calName, data)
+}
+
+// zoneName returns the name of t's location, or "" for a zero time.
+func zoneName(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Location().String()
+}
+
+// inZone moves t back into the named zone. It reports false if the zone
+// can't be loaded.
+func inZone(t time.Time, zone string) (time.Time, bool) {
+	if t.IsZero() || zone == "" {
+		return t, true
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return t, false
+	}
+	return t.In(loc), true
+}
+
+func toCachedDates(dates []eventDate) []cachedDate {
+	var cached []cachedDate
+	for _, d := range dates {
+		cached = append(cached, cachedDate{T: d.t, Zone: zoneName(d.t), AllDay: d.allDay})
+	}
+	return cached
+}
+
+func fromCachedDates(cached []cachedDate) ([]eventDate, bool) {
+	var dates []eventDate
+	for _, c := range cached {
+		t, ok := inZone(c.T, c.Zone)
+		if !ok {
+			return nil, false
+		}
+		dates = append(dates, eventDate{t: t, allDay: c.AllDay})
+	}
+	return dates, true
+}
@@ -0,0 +1,168 @@
+package calendar
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// Source types.
+const (
+	// SourceTypeICal is a static .ics feed fetched with GET (the default).
+	SourceTypeICal = "ical"
+	// SourceTypeCalDAV is a CalDAV calendar collection queried with REPORT.
+	SourceTypeCalDAV = "caldav"
+)
+
+// calendarQuery asks a CalDAV collection for the data of every VEVENT.
+const calendarQuery = `<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+  <d:prop>
+    <c:calendar-data/>
+  </d:prop>
+  <c:filter>
+    <c:comp-filter name="VCALENDAR">
+      <c:comp-filter name="VEVENT"/>
+    </c:comp-filter>
+  </c:filter>
+</c:calendar-query>
+`
+
+// multistatus is the subset of a WebDAV 207 response we read.
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Status       string `xml:"status"`
+			CalendarData string `xml:"prop>calendar-data"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// fetchCalDAV runs a calendar-query REPORT against the collection at
+// rawURL and merges the returned objects into one calendar.
+func fetchCalDAV(client *http.Client, cfg *Config, s Source, rawURL string) (*feed, error) {
+	u, err := davURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := davRequest(client, cfg, s, "REPORT", u.String(), "1", calendarQuery)
+	if err != nil {
+		return nil, fmt.Errorf("querying calendar: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, &RateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("querying calendar: HTTP %d", resp.StatusCode)
+	}
+
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("parsing CalDAV response: %w", err)
+	}
+
+	f := &feed{Calendar: ical.NewCalendar(), URL: rawURL}
+	if resp.Request.URL.String() != u.String() {
+		f.URL = resp.Request.URL.String()
+	}
+	for _, r := range ms.Responses {
+		for _, ps := range r.Propstat {
+			if ps.CalendarData == "" || !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			var obj *ical.Calendar
+			if cfg.Lenient {
+				var skipped int
+				obj, skipped, err = decodeLenient(strings.NewReader(ps.CalendarData))
+				f.Skipped += skipped
+			} else {
+				obj, err = ical.NewDecoder(strings.NewReader(ps.CalendarData)).Decode()
+			}
+			if err != nil {
+				if cfg.Lenient {
+					f.Skipped++
+					continue
+				}
+				return nil, fmt.Errorf("parsing %s: %w", r.Href, err)
+			}
+			if len(f.Calendar.Props) == 0 {
+				f.Calendar.Props = obj.Props
+			}
+			f.Calendar.Children = append(f.Calendar.Children, obj.Children...)
+		}
+	}
+	return f, nil
+}
+
+// verifyCalDAV checks that rawURL answers a PROPFIND like a DAV collection.
+func verifyCalDAV(client *http.Client, cfg *Config, s Source, rawURL string) error {
+	u, err := davURL(rawURL)
+	if err != nil {
+		return err
+	}
+	body := `<?xml version="1.0" encoding="utf-8"?><d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/></d:prop></d:propfind>`
+	resp, err := davRequest(client, cfg, s, "PROPFIND", u.String(), "0", body)
+	if err != nil {
+		return fmt.Errorf("verifying %s: %w", rawURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return fmt.Errorf("verifying %s: HTTP %d (expected a CalDAV collection)", rawURL, resp.StatusCode)
+	}
+	return nil
+}
+
+// davURL validates a CalDAV collection URL, which must be served over HTTP.
+func davURL(rawURL string) (*url.URL, error) {
+	u, err := validateSourceURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "file" {
+		return nil, fmt.Errorf("invalid URL %q: CalDAV sources need an http or https URL", rawURL)
+	}
+	return u, nil
+}
+
+// davRequest sends a WebDAV request with an XML body and the source's
+// credentials.
+func davRequest(client *http.Client, cfg *Config, s Source, method, rawURL, depth, body string) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawURL, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", cfg.userAgent())
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	req.Header.Set("Depth", depth)
+	if s.Username != "" {
+		req.SetBasicAuth(s.Username, s.password())
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP 401 (check the source's username and password)")
+	}
+	return resp, nil
+}
+
+// password returns the source's password from the environment variable
+// named by PasswordEnv.
+func (s Source) password() string {
+	if s.PasswordEnv == "" {
+		return ""
+	}
+	return os.Getenv(s.PasswordEnv)
+}
@@ -1,39 +1,93 @@
 package calendar
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
-	"os"
+	"net/url"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	ical "github.com/emersion/go-ical"
 )
 
-// FormatEventJSON returns a single event as indented JSON.
-func FormatEventJSON(e *Event) (string, error) {
-	data, err := json.MarshalIndent(e, "", "  ")
+// SchemaVersion is the version of the JSON shapes of Event, Source and
+// SourceStatus. It is bumped whenever a field is renamed, removed or changes
+// type; adding a field doesn't change it.
+const SchemaVersion = 1
+
+// WrapJSON wraps JSON produced by one of the Format*JSON functions in an
+// envelope of the form {"schema": SchemaVersion, key: data}, so consumers
+// can check the version before reading the data.
+func WrapJSON(key, data string, compact bool) (string, error) {
+	k, err := json.Marshal(key)
 	if err != nil {
 		return "", err
 	}
-	return string(data), nil
-}
-
-// FormatEventsJSON returns a slice of events as indented JSON.
-func FormatEventsJSON(events []Event) (string, error) {
-	data, err := json.MarshalIndent(events, "", "  ")
+	raw := fmt.Sprintf(`{"schema":%d,%s:%s}`, SchemaVersion, k, data)
+	var buf bytes.Buffer
+	if compact {
+		err = json.Compact(&buf, []byte(raw))
+	} else {
+		err = json.Indent(&buf, []byte(raw), "", "  ")
+	}
 	if err != nil {
 		return "", err
 	}
-	return string(data), nil
+	return buf.String(), nil
+}
+
+// FormatEventJSON returns a single event as JSON, indented unless compact.
+func FormatEventJSON(e *Event, compact bool) (string, error) {
+	return marshalJSON(e, compact)
+}
+
+// FormatEventsJSON returns a slice of events as JSON, indented unless compact.
+func FormatEventsJSON(events []Event, compact bool) (string, error) {
+	return marshalJSON(events, compact)
+}
+
+// FormatEventsJSONL writes events to w as JSON Lines, one compact object
+// per line, without building the whole array in memory.
+func FormatEventsJSONL(w io.Writer, events []Event) error {
+	enc := json.NewEncoder(w)
+	for i := range events {
+		if err := enc.Encode(&events[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-// FormatSourcesJSON returns a slice of sources as indented JSON.
-func FormatSourcesJSON(sources []Source) (string, error) {
-	data, err := json.MarshalIndent(sources, "", "  ")
+// FormatSourcesJSON returns a slice of sources as JSON, indented unless
+// compact.
+func FormatSourcesJSON(sources []Source, compact bool) (string, error) {
+	return marshalJSON(sources, compact)
+}
+
+// FormatStatusJSON returns source statuses as JSON, indented unless compact.
+func FormatStatusJSON(statuses []SourceStatus, compact bool) (string, error) {
+	return marshalJSON(statuses, compact)
+}
+
+func marshalJSON(v any, compact bool) (string, error) {
+	var data []byte
+	var err error
+	if compact {
+		data, err = json.Marshal(v)
+	} else {
+		data, err = json.MarshalIndent(v, "", "  ")
+	}
 	if err != nil {
 		return "", err
 	}
@@ -44,6 +98,51 @@ func FormatSourcesJSON(sources []Source) (string, error) {
 type Source struct {
 	Name string `json:"name"`
 	URL  string `json:"url"`
+	// URLs lists further feeds that make up the same calendar, for
+	// providers that split one calendar across several .ics files.
+	URLs []string `json:"urls,omitempty"`
+	// RefreshInterval is how long a sync stays fresh for `sync --if-stale`,
+	// as a Go duration such as "24h". Empty means always sync.
+	RefreshInterval string `json:"refresh_interval,omitempty"`
+	// Type is how the source is fetched: SourceTypeICal (the default when
+	// empty) or SourceTypeCalDAV.
+	Type string `json:"type,omitempty"`
+	// Username enables HTTP basic auth for CalDAV sources. The password is
+	// read from the environment variable named by PasswordEnv so it never
+	// lands in sources.json.
+	Username    string `json:"username,omitempty"`
+	PasswordEnv string `json:"password_env,omitempty"`
+	// Disabled keeps the source configured but skips it when syncing.
+	Disabled bool `json:"disabled,omitempty"`
+	// Insecure skips TLS certificate verification for this source's feeds,
+	// for dev servers with self-signed certificates.
+	Insecure bool `json:"insecure,omitempty"`
+	// Color is a display color for the calendar's events, such as
+	// "#4285f4", passed through to JSON output for renderers to theme with.
+	Color string `json:"color,omitempty"`
+}
+
+// Local reports whether the source is a local calendar with no feed, whose
+// events are created by hand rather than synced.
+func (s Source) Local() bool {
+	return s.URL == ""
+}
+
+// FeedURLs returns every feed URL of the source, starting with URL.
+func (s Source) FeedURLs() []string {
+	urls := []string{s.URL}
+	for _, u := range s.URLs {
+		if u != "" && !slices.Contains(urls, u) {
+			urls = append(urls, u)
+		}
+	}
+	return urls
+}
+
+// SyncMeta records the outcome of a calendar's last successful sync.
+type SyncMeta struct {
+	LastSync   time.Time `json:"last_sync"`
+	EventCount int       `json:"event_count"`
 }
 
 // Event represents a parsed calendar event.
@@ -52,15 +151,51 @@ type Event struct {
 	Summary     string
 	Description string
 	Location    string
+	URL         string
+	Status      string
 	Start       time.Time
 	End         time.Time
 	Calendar    string
 	AllDay      bool
+	Recurrence  string
+	Created     time.Time
+	Modified    time.Time
+	Stamp       time.Time
+	Sequence    int
+	// Priority is the PRIORITY value: 1 is highest, 9 lowest, 0 undefined.
+	Priority int
+	// Attachments holds the URLs of the event's ATTACH properties.
+	Attachments []string
+	// Categories holds the event's CATEGORIES values.
+	Categories []string `json:",omitempty"`
+	// Attendees holds the event's ATTENDEE properties.
+	Attendees []Attendee `json:",omitempty"`
+	// Lat and Lon are the event's GEO coordinates in degrees, both zero
+	// when it has none (see HasGeo).
+	Lat float64 `json:",omitempty"`
+	Lon float64 `json:",omitempty"`
+	// Color is the display color of the event's source calendar, if set.
+	Color string `json:",omitempty"`
+	// RecurrenceID is the original start of a recurring event's instance,
+	// and zero for non-recurring events and unexpanded masters.
+	RecurrenceID time.Time
+
+	exdates []eventDate
+	rdates  []eventDate
 }
 
 // CalendarManager handles calendar source management and event storage.
 type CalendarManager struct {
 	Config *Config
+	Store  Store
+	Client *http.Client
+	Log    *Logger
+	// Clock returns the current time; nil means time.Now. Replace it to pin
+	// "now" when testing ranges, syncs and edits.
+	Clock func() time.Time
+
+	// insecureClient serves sources with Insecure set; see clientFor.
+	insecureClient *http.Client
 }
 
 // NewCalendarManager creates a new CalendarManager with default config.
@@ -69,52 +204,209 @@ func NewCalendarManager() (*CalendarManager, error) {
 	if err != nil {
 		return nil, err
 	}
+	return NewCalendarManagerWithConfig(cfg)
+}
+
+// NewCalendarManagerWithConfig creates a CalendarManager using cfg, creating
+// its directory if needed.
+func NewCalendarManagerWithConfig(cfg *Config) (*CalendarManager, error) {
 	if err := cfg.EnsureDir(); err != nil {
 		return nil, err
 	}
-	return &CalendarManager{Config: cfg}, nil
+	client, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &CalendarManager{
+		Config: cfg,
+		Store:  NewFSStore(cfg),
+		Client: client,
+		Log:    NewLogger(),
+	}, nil
+}
+
+// Now returns the current time from Clock in the configured location.
+func (m *CalendarManager) Now() time.Time {
+	now := time.Now
+	if m.Clock != nil {
+		now = m.Clock
+	}
+	return now().In(m.Config.Location())
 }
 
 // --- Source Management ---
 
-// LoadSources reads the configured calendar sources from disk.
+// LoadSources reads the configured calendar sources from the store.
 func (m *CalendarManager) LoadSources() ([]Source, error) {
-	data, err := os.ReadFile(m.Config.SourcesFile())
+	return m.Store.LoadSources()
+}
+
+// SaveSources writes the calendar sources to the store, sorted by name so
+// listings are stable.
+func (m *CalendarManager) SaveSources(sources []Source) error {
+	sort.SliceStable(sources, func(i, j int) bool {
+		a, b := strings.ToLower(sources[i].Name), strings.ToLower(sources[j].Name)
+		if a != b {
+			return a < b
+		}
+		return sources[i].Name < sources[j].Name
+	})
+	return m.Store.SaveSources(sources)
+}
+
+// AddOptions controls how AddSource validates a new source.
+type AddOptions struct {
+	// CaseSensitive allows names that differ from an existing one only by case.
+	CaseSensitive bool
+	// SkipVerify skips the request that checks the URL serves a calendar.
+	SkipVerify bool
+	// AllowDuplicateURL allows a feed URL another source already uses.
+	AllowDuplicateURL bool
+}
+
+// AddSource adds a new calendar source. Names are unique ignoring case
+// unless opts.CaseSensitive is set, and the URL is checked to serve a
+// calendar unless opts.SkipVerify is set.
+func (m *CalendarManager) AddSource(src Source, opts AddOptions) error {
+	name := src.Name
+	switch src.Type {
+	case "", SourceTypeICal, SourceTypeCalDAV:
+	default:
+		return fmt.Errorf("invalid source type %q (use %s or %s)", src.Type, SourceTypeICal, SourceTypeCalDAV)
+	}
+	src.URLs = src.FeedURLs()[1:]
+	for _, url := range src.FeedURLs() {
+		if _, err := validateSourceURL(url); err != nil {
+			return err
+		}
+	}
+	if src.RefreshInterval != "" {
+		if _, err := time.ParseDuration(src.RefreshInterval); err != nil {
+			return fmt.Errorf("invalid refresh interval %q: %w", src.RefreshInterval, err)
+		}
+	}
+	sources, err := m.LoadSources()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
+		return err
+	}
+	for _, s := range sources {
+		if s.Name == name || (!opts.CaseSensitive && strings.EqualFold(s.Name, name)) {
+			return fmt.Errorf("calendar %q already exists", s.Name)
+		}
+		if opts.AllowDuplicateURL || s.Local() {
+			continue
+		}
+		for _, existing := range s.FeedURLs() {
+			for _, url := range src.FeedURLs() {
+				if normalizeSourceURL(existing) == normalizeSourceURL(url) {
+					return fmt.Errorf("calendar %q already uses %s (use --force to add it anyway)", s.Name, existing)
+				}
+			}
 		}
-		return nil, err
 	}
-	var sources []Source
-	if err := json.Unmarshal(data, &sources); err != nil {
-		return nil, err
+	if !opts.SkipVerify {
+		client, err := m.clientFor(src)
+		if err != nil {
+			return err
+		}
+		for _, url := range src.FeedURLs() {
+			verify := verifySourceURL(client, m.Config, url)
+			if src.Type == SourceTypeCalDAV {
+				verify = verifyCalDAV(client, m.Config, src, url)
+			}
+			if verify != nil {
+				return verify
+			}
+		}
 	}
-	return sources, nil
+	sources = append(sources, src)
+	return m.SaveSources(sources)
 }
 
-// SaveSources writes the calendar sources to disk.
-func (m *CalendarManager) SaveSources(sources []Source) error {
-	data, err := json.MarshalIndent(sources, "", "  ")
+// clientFor returns the HTTP client to fetch s with: Client, or for sources
+// with Insecure set, a copy that skips TLS certificate verification.
+func (m *CalendarManager) clientFor(s Source) (*http.Client, error) {
+	if !s.Insecure || m.Config.Insecure {
+		return m.Client, nil
+	}
+	if m.insecureClient == nil {
+		cfg := *m.Config
+		cfg.Insecure = true
+		client, err := newHTTPClient(&cfg)
+		if err != nil {
+			return nil, err
+		}
+		m.insecureClient = client
+	}
+	return m.insecureClient, nil
+}
+
+// normalizeSourceURL reduces a feed URL to a form where webcal, http and
+// https variants of the same feed, or ones differing only by a trailing
+// slash or host case, compare equal.
+func normalizeSourceURL(rawURL string) string {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
 	if err != nil {
-		return err
+		return rawURL
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "webcal":
+		u.Scheme = "https"
 	}
-	return os.WriteFile(m.Config.SourcesFile(), data, 0644)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/")
+	u.Fragment = ""
+	return u.String()
 }
 
-// AddSource adds a new calendar source.
-func (m *CalendarManager) AddSource(name, url string) error {
-	sources, err := m.LoadSources()
+// suggestNameTimeout bounds the fetch SuggestSourceName makes, which only
+// needs the start of the feed.
+var suggestNameTimeout = 10 * time.Second
+
+// SuggestSourceName proposes a name for the feed at url: its X-WR-CALNAME
+// if it can be fetched within suggestNameTimeout, otherwise the URL's host
+// or file name. Only the feed's header is read, not its events.
+func (m *CalendarManager) SuggestSourceName(rawURL string) string {
+	client := *m.Client
+	client.Timeout = suggestNameTimeout
+	if body, _, err := openFeed(&client, m.Config, rawURL); err == nil {
+		name := feedName(body)
+		body.Close()
+		if name != "" {
+			return name
+		}
+	}
+	u, err := validateSourceURL(rawURL)
 	if err != nil {
-		return err
+		return ""
 	}
-	for _, s := range sources {
-		if s.Name == name {
-			return fmt.Errorf("calendar %q already exists", name)
+	if u.Scheme == "file" {
+		base := filepath.Base(u.Path)
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
+
+// findSource returns the index in sources of the calendar name: the one
+// with exactly that name if there is one, otherwise the only one whose name
+// matches ignoring case. It returns -1 when none match, and an error when
+// several differ from name only in case, as --case-sensitive allows.
+func findSource(sources []Source, name string) (int, error) {
+	if i := slices.IndexFunc(sources, func(s Source) bool { return s.Name == name }); i >= 0 {
+		return i, nil
+	}
+	found := -1
+	var matches []string
+	for i, s := range sources {
+		if strings.EqualFold(s.Name, name) {
+			found = i
+			matches = append(matches, strconv.Quote(s.Name))
 		}
 	}
-	sources = append(sources, Source{Name: name, URL: url})
-	return m.SaveSources(sources)
+	if len(matches) > 1 {
+		return -1, fmt.Errorf("calendar %q matches %s; use the exact name", name, strings.Join(matches, " and "))
+	}
+	return found, nil
 }
 
 // RemoveSource removes a calendar source and its local events.
@@ -123,295 +415,1543 @@ func (m *CalendarManager) RemoveSource(name string) error {
 	if err != nil {
 		return err
 	}
-	var filtered []Source
-	found := false
-	for _, s := range sources {
-		if s.Name == name {
-			found = true
-			continue
-		}
-		filtered = append(filtered, s)
+	i, err := findSource(sources, name)
+	if err != nil {
+		return err
 	}
-	if !found {
+	if i < 0 {
 		return fmt.Errorf("calendar %q not found", name)
 	}
-	os.RemoveAll(m.Config.CalendarDir(name))
+	name = sources[i].Name
+	filtered := slices.Delete(sources, i, i+1)
+	if err := m.Store.BackupSources(); err != nil {
+		return fmt.Errorf("backing up sources: %w", err)
+	}
+	if err := m.Store.DeleteCalendar(name); err != nil {
+		return fmt.Errorf("deleting events of %q: %w", name, err)
+	}
 	return m.SaveSources(filtered)
 }
 
-// --- Sync ---
-
-// SyncAll syncs all configured calendar sources.
-func (m *CalendarManager) SyncAll() error {
+// SetSourceEnabled enables or disables syncing the calendar name, found by
+// findSource. A disabled calendar keeps its events and settings.
+func (m *CalendarManager) SetSourceEnabled(name string, enabled bool) error {
 	sources, err := m.LoadSources()
 	if err != nil {
 		return err
 	}
-	if len(sources) == 0 {
-		return fmt.Errorf("no calendars configured, use 'add' to add one")
+	i, err := findSource(sources, name)
+	if err != nil {
+		return err
 	}
-	for _, s := range sources {
-		fmt.Printf("syncing %s...\n", s.Name)
-		if err := m.syncSource(s); err != nil {
-			fmt.Printf("  error: %v\n", err)
-			continue
-		}
+	if i < 0 {
+		return fmt.Errorf("calendar %q not found", name)
 	}
-	return nil
+	if sources[i].Local() {
+		return fmt.Errorf("calendar %q is local and is never synced", sources[i].Name)
+	}
+	sources[i].Disabled = !enabled
+	return m.SaveSources(sources)
 }
 
-func (m *CalendarManager) syncSource(s Source) error {
-	resp, err := http.Get(s.URL)
+// MergeSources moves the events of the srcs calendars into dest and removes
+// srcs. When two calendars hold the same UID, the copy with the higher
+// SEQUENCE wins, and dest's copy on a tie. A synced dest takes over the
+// srcs' feed URLs so their events keep syncing; local calendars can't be
+// merged into a synced one, nor synced ones into a local one, since the
+// next sync or the lack of one would lose events.
+func (m *CalendarManager) MergeSources(dest string, srcs []string) error {
+	sources, err := m.LoadSources()
 	if err != nil {
-		return fmt.Errorf("fetching calendar: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("fetching calendar: HTTP %d", resp.StatusCode)
+		return err
 	}
-
-	dec := ical.NewDecoder(resp.Body)
-	cal, err := dec.Decode()
+	// Names match as findSource does, and are replaced by the stored ones.
+	di, err := findSource(sources, dest)
 	if err != nil {
-		return fmt.Errorf("parsing calendar: %w", err)
-	}
-
-	dir := m.Config.CalendarDir(s.Name)
-	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
-
-	// Clear existing events before writing fresh data
-	entries, _ := os.ReadDir(dir)
-	for _, e := range entries {
-		os.Remove(filepath.Join(dir, e.Name()))
+	if di < 0 {
+		return fmt.Errorf("calendar %q not found", dest)
 	}
-
-	count := 0
-	for _, event := range cal.Events() {
-		uid, err := event.Props.Text(ical.PropUID)
-		if err != nil || uid == "" {
+	dest = sources[di].Name
+	if len(srcs) == 0 {
+		return fmt.Errorf("no calendars to merge into %q", dest)
+	}
+	merging := make(map[string]bool, len(srcs))
+	var names []string
+	for _, name := range srcs {
+		si, err := findSource(sources, name)
+		if err != nil {
+			return err
+		}
+		if si >= 0 {
+			name = sources[si].Name
+		}
+		switch {
+		case si < 0:
+			return fmt.Errorf("calendar %q not found", name)
+		case merging[name]:
 			continue
+		case name == dest:
+			return fmt.Errorf("can't merge %q into itself", name)
+		case sources[di].Local() && !sources[si].Local():
+			return fmt.Errorf("calendar %q is synced from a feed and %q is local; merge it into a synced calendar", name, dest)
+		case !sources[di].Local() && sources[si].Local():
+			return fmt.Errorf("calendar %q is local; merging it into synced %q would lose its events on the next sync", name, dest)
 		}
+		merging[name] = true
+		names = append(names, name)
+		if !sources[di].Local() {
+			sources[di].URLs = append(sources[di].URLs, sources[si].FeedURLs()...)
+		}
+	}
+	sources[di].URLs = sources[di].FeedURLs()[1:]
 
-		// Wrap the event in its own calendar object so the .ics file is valid
-		eventCal := ical.NewCalendar()
-		eventCal.Props.SetText(ical.PropVersion, "2.0")
-		eventCal.Props.SetText(ical.PropProductID, "-//arjungandhi/calendar//EN")
-		eventCal.Children = append(eventCal.Children, event.Component)
-
-		var buf strings.Builder
-		enc := ical.NewEncoder(&buf)
-		if err := enc.Encode(eventCal); err != nil {
-			continue
+	existing, err := m.storedEvents(dest)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		events, err := m.storedEvents(name)
+		if err != nil {
+			return err
+		}
+		for uid, data := range events {
+			if old, ok := existing[uid]; ok && !m.newerEvent(data, old, name, dest) {
+				continue
+			}
+			if err := m.Store.SaveEvent(dest, uid, data); err != nil {
+				return err
+			}
+			existing[uid] = data
 		}
+	}
 
-		filename := sanitizeFilename(uid) + ".ics"
-		if err := os.WriteFile(filepath.Join(dir, filename), []byte(buf.String()), 0644); err != nil {
-			continue
+	var kept []Source
+	for _, s := range sources {
+		if !merging[s.Name] {
+			kept = append(kept, s)
+		}
+	}
+	if err := m.Store.BackupSources(); err != nil {
+		return fmt.Errorf("backing up sources: %w", err)
+	}
+	if err := m.SaveSources(kept); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := m.Store.DeleteCalendar(name); err != nil {
+			return fmt.Errorf("deleting events of %q: %w", name, err)
 		}
-		count++
 	}
-	fmt.Printf("  %d events synced\n", count)
 	return nil
 }
 
-// --- Event Retrieval ---
+// newerEvent reports whether the stored event data from calendar calA has a
+// higher SEQUENCE than old from calB.
+func (m *CalendarManager) newerEvent(data, old []byte, calA, calB string) bool {
+	a, err := readEvent(data, calA, m.Config.Location())
+	if err != nil {
+		return false
+	}
+	b, err := readEvent(old, calB, m.Config.Location())
+	if err != nil {
+		return true
+	}
+	return a.Sequence > b.Sequence
+}
 
-// ListEvents returns events within the given time range from all calendars.
-func (m *CalendarManager) ListEvents(from, to time.Time) ([]Event, error) {
+// RestoreSources rolls sources back to the most recent backup. Events of
+// restored calendars return on the next sync.
+func (m *CalendarManager) RestoreSources() error {
+	return m.Store.RestoreSources()
+}
+
+// SourceStatus summarizes a source's local state.
+type SourceStatus struct {
+	Source
+	LastSync   time.Time `json:"last_sync,omitzero"`
+	EventCount int       `json:"event_count"`
+	Enabled    bool      `json:"enabled"`
+}
+
+// Synced reports whether the source has ever been synced.
+func (s SourceStatus) Synced() bool {
+	return !s.LastSync.IsZero()
+}
+
+// Status reports the last sync time and stored event count of every source.
+// Sources that were never synced have a zero LastSync and no events.
+func (m *CalendarManager) Status() ([]SourceStatus, error) {
 	sources, err := m.LoadSources()
 	if err != nil {
 		return nil, err
 	}
-
-	var events []Event
+	statuses := make([]SourceStatus, 0, len(sources))
 	for _, s := range sources {
-		calEvents, err := m.loadCalendarEvents(s.Name)
+		meta, err := m.Store.LoadMeta(s.Name)
 		if err != nil {
-			continue
+			return nil, err
 		}
-		events = append(events, calEvents...)
-	}
-
-	var filtered []Event
-	for _, e := range events {
-		if !from.IsZero() && e.Start.Before(from) {
-			continue
-		}
-		if !to.IsZero() && e.Start.After(to) {
-			continue
+		raws, err := m.Store.LoadEvents(s.Name)
+		if err != nil {
+			return nil, err
 		}
-		filtered = append(filtered, e)
+		statuses = append(statuses, SourceStatus{
+			Source:     s,
+			LastSync:   meta.LastSync,
+			EventCount: len(raws),
+			Enabled:    !s.Disabled,
+		})
 	}
+	return statuses, nil
+}
 
-	sort.Slice(filtered, func(i, j int) bool {
-		return filtered[i].Start.Before(filtered[j].Start)
-	})
+// --- Sync ---
 
-	return filtered, nil
+// SyncOptions controls which sources SyncAll fetches.
+type SyncOptions struct {
+	// IfStale skips sources synced within their RefreshInterval.
+	IfStale bool
+	// AllowEmpty lets a feed that returns no events clear a calendar that
+	// has some. Without it the stored events are kept, since an empty feed
+	// is more often a provider hiccup than a cleared calendar.
+	AllowEmpty bool
 }
 
-func (m *CalendarManager) loadCalendarEvents(calName string) ([]Event, error) {
-	dir := m.Config.CalendarDir(calName)
-	entries, err := os.ReadDir(dir)
+// SyncAll syncs all configured calendar sources and returns the result of
+// each one that synced. Every source is attempted; failures are returned
+// together as a joined error. Cancelling ctx stops a wait for a rate
+// limited feed.
+func (m *CalendarManager) SyncAll(ctx context.Context, opts SyncOptions) ([]SyncResult, error) {
+	sources, err := m.LoadSources()
 	if err != nil {
 		return nil, err
 	}
-
-	var events []Event
-	for _, entry := range entries {
-		if !strings.HasSuffix(entry.Name(), ".ics") {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no calendars configured, use 'add' to add one")
+	}
+	unlock, err := acquireLock(m.Config.LockFile())
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if m.Config.Insecure {
+		m.Log.Errorf("warning: TLS certificate verification is disabled (--insecure)\n")
+	}
+	var results []SyncResult
+	var errs []error
+	record := SyncRecord{Time: m.Now().UTC().Truncate(time.Second)}
+	defer func() {
+		if len(record.Sources) == 0 {
+			return
+		}
+		if err := m.appendSyncLog(record); err != nil {
+			m.Log.Errorf("warning: writing sync log: %v\n", err)
+		}
+	}()
+	for _, s := range sources {
+		if s.Local() {
+			continue
+		}
+		if s.Disabled {
+			m.Log.Infof("skipping %s (disabled)\n", s.Name)
+			continue
+		}
+		if opts.IfStale {
+			if fresh, age := m.isFresh(s); fresh {
+				m.Log.Infof("skipping %s (synced %s ago)\n", s.Name, age.Round(time.Minute))
+				continue
+			}
+		}
+		m.Log.Infof("syncing %s...\n", s.Name)
+		if s.Insecure && !m.Config.Insecure {
+			m.Log.Errorf("warning: TLS certificate verification is disabled for %s\n", s.Name)
+		}
+		res, err := m.syncSource(ctx, s, opts)
+		if errors.As(err, new(*RateLimitError)) {
+			// The feed is up, just busy; its events are kept until next time.
+			m.Log.Errorf("  skipping %s: %v\n", s.Name, err)
+			record.Sources = append(record.Sources, SyncSourceRecord{Calendar: s.Name, Error: err.Error()})
 			continue
 		}
-		path := filepath.Join(dir, entry.Name())
-		event, err := readEvent(path, calName)
 		if err != nil {
+			if m.Log.Level == LogQuiet {
+				m.Log.Errorf("%s: error: %v\n", s.Name, err)
+			} else {
+				m.Log.Errorf("  error: %v\n", err)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name, err))
+			record.Sources = append(record.Sources, SyncSourceRecord{Calendar: s.Name, Error: err.Error()})
 			continue
 		}
-		events = append(events, *event)
+		m.Log.Infof("  %d added, %d updated, %d removed, %d unchanged\n",
+			res.Added, res.Updated, res.Removed, res.Unchanged)
+		if res.Skipped > 0 {
+			m.Log.Infof("  %d malformed events skipped\n", res.Skipped)
+		}
+		var feedErrs []string
+		for _, fe := range res.FeedErrors {
+			if m.Log.Level == LogQuiet {
+				m.Log.Errorf("%s: error: %v\n", s.Name, fe)
+			} else {
+				m.Log.Errorf("  error: %v\n", fe)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", s.Name, fe))
+			feedErrs = append(feedErrs, fe.Error())
+		}
+		results = append(results, res)
+		record.Sources = append(record.Sources, SyncSourceRecord{
+			Calendar:  res.Calendar,
+			Added:     res.Added,
+			Updated:   res.Updated,
+			Removed:   res.Removed,
+			Unchanged: res.Unchanged,
+			Skipped:   res.Skipped,
+			Error:     strings.Join(feedErrs, "; "),
+		})
 	}
-	return events, nil
+	return results, errors.Join(errs...)
 }
 
-func readEvent(path, calName string) (*Event, error) {
-	data, err := os.ReadFile(path)
+// SyncSource syncs the one calendar name, holding the same lock as SyncAll
+// so the two never write a calendar at once. If some of its feeds fail,
+// the result is returned along with their FeedErrors joined. With
+// opts.IfStale, a calendar synced recently is skipped and reported Fresh.
+func (m *CalendarManager) SyncSource(ctx context.Context, name string, opts SyncOptions) (SyncResult, error) {
+	sources, err := m.LoadSources()
 	if err != nil {
-		return nil, err
+		return SyncResult{}, err
 	}
-
-	dec := ical.NewDecoder(strings.NewReader(string(data)))
-	cal, err := dec.Decode()
+	i, err := findSource(sources, name)
 	if err != nil {
-		return nil, err
+		return SyncResult{}, err
 	}
-
-	icalEvents := cal.Events()
-	if len(icalEvents) == 0 {
-		return nil, fmt.Errorf("no events in file")
+	if i < 0 {
+		return SyncResult{}, fmt.Errorf("calendar %q not found", name)
 	}
-
-	ie := icalEvents[0]
-	uid, _ := ie.Props.Text(ical.PropUID)
-	summary, _ := ie.Props.Text(ical.PropSummary)
-	description, _ := ie.Props.Text(ical.PropDescription)
-	location, _ := ie.Props.Text(ical.PropLocation)
-
-	start, allDay := parseEventTime(&ie, ical.PropDateTimeStart)
-	end, _ := parseEventTime(&ie, ical.PropDateTimeEnd)
-
-	return &Event{
-		UID:         uid,
-		Summary:     summary,
-		Description: description,
-		Location:    location,
-		Start:       start,
-		End:         end,
-		Calendar:    calName,
-		AllDay:      allDay,
-	}, nil
-}
-
-func parseEventTime(event *ical.Event, prop string) (time.Time, bool) {
-	p := event.Props.Get(prop)
-	if p == nil {
-		return time.Time{}, false
+	s := sources[i]
+	if s.Local() {
+		return SyncResult{}, fmt.Errorf("calendar %q is local and has no feed to sync", s.Name)
 	}
-
-	// Check if it's an all-day event (VALUE=DATE)
-	allDay := false
-	if values, ok := p.Params["VALUE"]; ok {
-		for _, v := range values {
-			if v == "DATE" {
-				allDay = true
-			}
+	if opts.IfStale {
+		if fresh, age := m.isFresh(s); fresh {
+			m.Log.Infof("skipping %s (synced %s ago)\n", s.Name, age.Round(time.Minute))
+			return SyncResult{Calendar: s.Name, Fresh: true}, nil
 		}
 	}
-
-	// Try to resolve timezone from TZID parameter
-	loc := time.Local
-	if tzids, ok := p.Params["TZID"]; ok && len(tzids) > 0 {
-		if l, err := time.LoadLocation(tzids[0]); err == nil {
-			loc = l
-		}
+	unlock, err := acquireLock(m.Config.LockFile())
+	if err != nil {
+		return SyncResult{}, err
 	}
-
-	if allDay {
-		t, err := time.Parse("20060102", p.Value)
-		if err != nil {
-			return time.Time{}, false
-		}
-		return t, true
+	defer unlock()
+	if m.Config.Insecure || s.Insecure {
+		m.Log.Errorf("warning: TLS certificate verification is disabled for %s\n", s.Name)
+	}
+	res, err := m.syncSource(ctx, s, opts)
+	if err != nil {
+		return res, err
+	}
+	errs := make([]error, len(res.FeedErrors))
+	for i, fe := range res.FeedErrors {
+		errs[i] = fe
 	}
+	return res, errors.Join(errs...)
+}
 
-	t, err := p.DateTime(loc)
+// isFresh reports whether s was synced within its refresh interval, along
+// with the time since its last sync.
+func (m *CalendarManager) isFresh(s Source) (bool, time.Duration) {
+	if s.RefreshInterval == "" {
+		return false, 0
+	}
+	interval, err := time.ParseDuration(s.RefreshInterval)
 	if err != nil {
-		// Fallback: try parsing as date only
-		t, err = time.Parse("20060102", p.Value)
-		if err != nil {
-			return time.Time{}, false
-		}
-		return t, true
+		return false, 0
 	}
-	return t, false
+	meta, err := m.Store.LoadMeta(s.Name)
+	if err != nil || meta.LastSync.IsZero() {
+		return false, 0
+	}
+	age := m.Now().Sub(meta.LastSync)
+	return age < interval, age
 }
 
-// GetEventICS returns the raw ICS data for an event by UID.
-func (m *CalendarManager) GetEventICS(uid string) (string, error) {
-	_, raw, err := m.GetEvent(uid)
-	return raw, err
+// SyncResult counts the changes a sync made to a calendar's stored events.
+type SyncResult struct {
+	Calendar  string
+	Added     int
+	Updated   int
+	Removed   int
+	Unchanged int
+	// Skipped counts malformed events dropped in lenient mode.
+	Skipped int
+	// FeedErrors lists the feeds of a multi-feed source that couldn't be
+	// fetched. The events of the others are synced, but none are removed,
+	// since those of a failed feed can't be told apart.
+	FeedErrors []*FeedError
+	// Fresh is set when SyncSource skipped the calendar because
+	// SyncOptions.IfStale was given and it was synced recently.
+	Fresh bool
 }
 
-// GetEvent finds an event by UID across all calendars.
-func (m *CalendarManager) GetEvent(uid string) (*Event, string, error) {
-	sources, err := m.LoadSources()
+// FeedError is a failure to fetch one feed of a source that has several.
+type FeedError struct {
+	URL string
+	Err error
+}
+
+func (e *FeedError) Error() string {
+	return e.URL + ": " + e.Err.Error()
+}
+
+func (e *FeedError) Unwrap() error {
+	return e.Err
+}
+
+// Changed reports whether the sync added, updated or removed any events.
+func (r SyncResult) Changed() bool {
+	return r.Added+r.Updated+r.Removed > 0
+}
+
+func (m *CalendarManager) syncSource(ctx context.Context, s Source, opts SyncOptions) (SyncResult, error) {
+	res := SyncResult{Calendar: s.Name}
+	incoming, skipped, feedErrs, err := m.fetchSource(ctx, s)
 	if err != nil {
-		return nil, "", err
+		return res, err
+	}
+	res.Skipped = skipped
+	res.FeedErrors = feedErrs
+	existing, err := m.storedEvents(s.Name)
+	if err != nil {
+		return res, err
+	}
+	if len(incoming) == 0 && len(existing) > 0 && !opts.AllowEmpty {
+		m.Log.Errorf("  warning: %s returned no events; keeping the %d stored ones (use --allow-empty to clear them)\n",
+			s.Name, len(existing))
+		res.Unchanged = len(existing)
+		return res, nil
+	}
+
+	// Only write events whose serialized form changed so unchanged files
+	// keep their mtimes. eventCount is how many events end up stored,
+	// including old copies left behind by failed writes.
+	eventCount := 0
+	var writeErrs []error
+	for uid, data := range incoming {
+		old, ok := existing[uid]
+		if ok && bytes.Equal(old, data) {
+			res.Unchanged++
+			eventCount++
+			continue
+		}
+		if err := m.Store.SaveEvent(s.Name, uid, data); err != nil {
+			if errors.Is(err, ErrUnsafeUID) {
+				m.Log.Errorf("  skipping event: %v\n", err)
+			} else {
+				m.Log.Errorf("  error: saving %s: %v\n", uid, err)
+				writeErrs = append(writeErrs, fmt.Errorf("saving %s: %w", uid, err))
+			}
+			if ok {
+				eventCount++
+			}
+			continue
+		}
+		eventCount++
+		if ok {
+			m.Log.Debugf("  updated %s\n", uid)
+			res.Updated++
+		} else {
+			m.Log.Debugf("  added %s\n", uid)
+			res.Added++
+		}
+		// Warned about only when the event changes, not on every sync or
+		// read.
+		for _, w := range timeWarnings(data) {
+			m.Log.Errorf("  warning: %s: %s\n", uid, w)
+		}
+	}
+	for uid := range existing {
+		if _, ok := incoming[uid]; ok {
+			continue
+		}
+		if len(feedErrs) > 0 {
+			// It may belong to the feed that failed.
+			res.Unchanged++
+			eventCount++
+			continue
+		}
+		if err := m.Store.DeleteEvent(s.Name, uid); err != nil {
+			m.Log.Errorf("  error: removing %s: %v\n", uid, err)
+			writeErrs = append(writeErrs, fmt.Errorf("removing %s: %w", uid, err))
+			eventCount++
+			continue
+		}
+		m.Log.Debugf("  removed %s\n", uid)
+		res.Removed++
+	}
+	// Files that don't parse have no UID to match against the feed, so the
+	// loop above never removes them.
+	if q, ok := m.Store.(EventQuarantine); ok {
+		loc := m.Config.Location()
+		moved, err := q.QuarantineEvents(s.Name, func(data []byte) bool {
+			_, err := readEvent(data, s.Name, loc)
+			return err != nil
+		})
+		for _, path := range moved {
+			m.Log.Errorf("  warning: moved unreadable stored event to %s\n", path)
+		}
+		if err != nil {
+			m.Log.Errorf("  warning: quarantining unreadable events: %v\n", err)
+		}
+	}
+
+	meta := SyncMeta{LastSync: m.Now(), EventCount: eventCount}
+	if err := m.Store.SaveMeta(s.Name, meta); err != nil {
+		return res, err
+	}
+	if err := m.saveEventCache(s.Name); err != nil {
+		m.Log.Debugf("  failed to write event cache: %v\n", err)
+	}
+	if len(writeErrs) > 0 {
+		return res, fmt.Errorf("%d events could not be written: %w", len(writeErrs), writeErrs[0])
+	}
+	return res, nil
+}
+
+// splitEvents groups VEVENTs by UID and encodes each group as its own
+// calendar object, keyed by UID. A group holds the master event followed by
+// any RECURRENCE-ID overrides. Events without a UID are skipped, and when
+// the same instance repeats the one with the higher SEQUENCE wins. Each
+// object carries the VTIMEZONEs its events reference; raw keeps the feed's
+// calendar-level properties instead of replacing them with our own.
+func splitEvents(cal *ical.Calendar, prodID string, raw bool) map[string][]byte {
+	timezones := make(map[string]*ical.Component)
+	for _, child := range cal.Children {
+		if child.Name != ical.CompTimezone {
+			continue
+		}
+		if tzid, err := child.Props.Text(ical.PropTimezoneID); err == nil && tzid != "" {
+			timezones[tzid] = child
+		}
+	}
+
+	type instance struct {
+		recurrenceID string
+		seq          int
+		comp         *ical.Component
+	}
+	groups := make(map[string][]instance)
+	for _, event := range cal.Events() {
+		uid, err := event.Props.Text(ical.PropUID)
+		if err != nil || uid == "" {
+			continue
+		}
+		inst := instance{seq: eventSequence(&event), comp: event.Component}
+		if p := event.Props.Get(ical.PropRecurrenceID); p != nil {
+			inst.recurrenceID = p.Value
+		}
+
+		replaced := false
+		for i, prev := range groups[uid] {
+			if prev.recurrenceID != inst.recurrenceID {
+				continue
+			}
+			if inst.seq >= prev.seq {
+				groups[uid][i] = inst
+			}
+			replaced = true
+			break
+		}
+		if !replaced {
+			groups[uid] = append(groups[uid], inst)
+		}
 	}
 
+	events := make(map[string][]byte, len(groups))
+	for uid, insts := range groups {
+		// The master (no RECURRENCE-ID) sorts first.
+		sort.Slice(insts, func(i, j int) bool {
+			return insts[i].recurrenceID < insts[j].recurrenceID
+		})
+
+		// Wrap the events in their own calendar object so the .ics file is
+		// valid. Property values keep their escaped wire form, so re-encoding
+		// preserves RFC 5545 text escaping without an extra pass.
+		eventCal := ical.NewCalendar()
+		if raw {
+			for name, props := range cal.Props {
+				eventCal.Props[name] = props
+			}
+		} else {
+			eventCal.Props.SetText(ical.PropVersion, "2.0")
+			eventCal.Props.SetText(ical.PropProductID, prodID)
+			// Keep the feed-level zone so floating times still resolve against
+			// it once the event is read back on its own.
+			if tz := cal.Props.Get("X-WR-TIMEZONE"); tz != nil {
+				eventCal.Props.Set(tz)
+			}
+		}
+		var tzids []string
+		for _, inst := range insts {
+			tzids = append(tzids, componentTZIDs(inst.comp)...)
+		}
+		slices.Sort(tzids)
+		for _, tzid := range slices.Compact(tzids) {
+			if tz, ok := timezones[tzid]; ok {
+				eventCal.Children = append(eventCal.Children, tz)
+			}
+		}
+		for _, inst := range insts {
+			eventCal.Children = append(eventCal.Children, inst.comp)
+		}
+
+		var buf bytes.Buffer
+		enc := ical.NewEncoder(&buf)
+		if err := enc.Encode(eventCal); err != nil {
+			continue
+		}
+		events[uid] = []byte(normalizeCRLF(buf.String()))
+	}
+	return events
+}
+
+// componentTZIDs returns the TZID parameters used by comp's properties and
+// those of its subcomponents, such as alarms.
+func componentTZIDs(comp *ical.Component) []string {
+	var tzids []string
+	for _, props := range comp.Props {
+		for _, p := range props {
+			if tzid, ok := paramValue(&p, ical.ParamTimezoneID); ok {
+				tzids = append(tzids, tzid)
+			}
+		}
+	}
+	for _, child := range comp.Children {
+		tzids = append(tzids, componentTZIDs(child)...)
+	}
+	return tzids
+}
+
+// fetchSource fetches every feed of s and splits the events into files as
+// they would be stored, keyed by UID, along with the number of malformed
+// events skipped. When some feeds fail and others don't, the failures are
+// returned as FeedErrors beside the events of the rest; when all fail, the
+// error is returned.
+func (m *CalendarManager) fetchSource(ctx context.Context, s Source) (map[string][]byte, int, []*FeedError, error) {
+	client, err := m.clientFor(s)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	// Feeds are merged into the first one's calendar; splitEvents then
+	// dedupes events that appear in more than one feed by UID.
+	var cal *ical.Calendar
+	var feedErrs []*FeedError
+	skipped := 0
+	urls := s.FeedURLs()
+	for _, url := range urls {
+		started := time.Now()
+		f, err := m.fetchFeed(ctx, client, s, url)
+		if err != nil {
+			if len(urls) == 1 {
+				return nil, 0, nil, err
+			}
+			feedErrs = append(feedErrs, &FeedError{URL: url, Err: err})
+			continue
+		}
+		m.Log.Debugf("  fetched %s in %s\n", f.URL, time.Since(started).Round(time.Millisecond))
+		if f.URL != url {
+			m.Log.Infof("  redirected to %s\n", f.URL)
+		}
+		skipped += f.Skipped
+		if cal == nil {
+			cal = f.Calendar
+		} else {
+			cal.Children = append(cal.Children, f.Calendar.Children...)
+		}
+	}
+	if cal == nil {
+		errs := make([]error, len(feedErrs))
+		for i, fe := range feedErrs {
+			errs[i] = fe
+		}
+		return nil, 0, nil, errors.Join(errs...)
+	}
+
+	if tz, err := cal.Props.Text("X-WR-TIMEZONE"); err == nil && tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			m.Log.Errorf("  warning: unknown X-WR-TIMEZONE %q, using %s\n", tz, m.Config.Location())
+		}
+	}
+	if m.Log.Level >= LogVerbose {
+		for _, w := range tzOffsetWarnings(cal) {
+			m.Log.Debugf("  warning: %s\n", w)
+		}
+	}
+	return splitEvents(cal, m.Config.ProductID, m.Config.EventFormat == EventFormatRaw), skipped, feedErrs, nil
+}
+
+// fetchFeed fetches one feed of s. A feed that answers 429 is retried once
+// after its Retry-After, if that is within Config.MaxRetryAfter, unless ctx
+// is cancelled while waiting.
+func (m *CalendarManager) fetchFeed(ctx context.Context, client *http.Client, s Source, url string) (*feed, error) {
+	fetch := func() (*feed, error) {
+		if s.Type == SourceTypeCalDAV {
+			return fetchCalDAV(client, m.Config, s, url)
+		}
+		return fetchCalendar(client, m.Config, url)
+	}
+	f, err := fetch()
+	var limited *RateLimitError
+	if !errors.As(err, &limited) {
+		return f, err
+	}
+	max := time.Duration(m.Config.MaxRetryAfter) * time.Second
+	if max <= 0 {
+		max = DefaultMaxRetryAfter * time.Second
+	}
+	if limited.RetryAfter > max {
+		return nil, fmt.Errorf("%w; not waiting longer than max_retry_after (%s)", err, max)
+	}
+	m.Log.Infof("  rate limited, retrying in %s\n", limited.RetryAfter)
+	timer := time.NewTimer(limited.RetryAfter)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return fetch()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// storedEvents returns the raw data of a calendar's stored events keyed by
+// UID. A calendar that has never been synced has no stored events.
+func (m *CalendarManager) storedEvents(calName string) (map[string][]byte, error) {
+	raws, err := m.Store.LoadEvents(calName)
+	if err != nil {
+		return nil, err
+	}
+	events := make(map[string][]byte, len(raws))
+	for _, data := range raws {
+		event, err := readEvent(data, calName, m.Config.Location())
+		if err != nil {
+			continue
+		}
+		events[event.UID] = data
+	}
+	return events, nil
+}
+
+// --- Event Retrieval ---
+
+// ListEvents returns events from all calendars that start in [from, to):
+// from is inclusive and to is an exclusive upper bound, so an event starting
+// exactly at to is left out. A zero bound leaves that side open.
+func (m *CalendarManager) ListEvents(from, to time.Time) ([]Event, error) {
+	sources, err := m.LoadSources()
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
 	for _, s := range sources {
-		dir := m.Config.CalendarDir(s.Name)
-		entries, _ := os.ReadDir(dir)
-		for _, entry := range entries {
-			if !strings.HasSuffix(entry.Name(), ".ics") {
+		calEvents, err := m.loadCalendarEvents(s.Name)
+		if err != nil {
+			continue
+		}
+		for i := range calEvents {
+			calEvents[i].Color = s.Color
+		}
+		events = append(events, expandRecurrences(calEvents, from, to, m.Now())...)
+	}
+
+	var filtered []Event
+	for _, e := range events {
+		if startsIn(e, from, to) {
+			filtered = append(filtered, e)
+		}
+	}
+
+	SortEvents(filtered)
+
+	return filtered, nil
+}
+
+// overlappingEvents is like ListEvents but also returns the events, and
+// instances, that started before from and are still going on at from,
+// however long ago they started.
+func (m *CalendarManager) overlappingEvents(from, to time.Time) ([]Event, error) {
+	events, err := m.ListEvents(time.Time{}, to)
+	if err != nil {
+		return nil, err
+	}
+	var out []Event
+	for _, e := range events {
+		if !e.Start.Before(from) || e.End.After(from) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// StopIteration can be returned by an IterateEvents callback to stop early
+// without IterateEvents returning an error.
+var StopIteration = errors.New("stop iteration")
+
+// IterateEvents calls fn for each event that starts in [from, to), like
+// ListEvents, but reads and expands one stored file at a time instead of
+// building the whole list, so huge ranges don't have to fit in memory.
+// Events come calendar by calendar in no particular order. Iteration stops
+// at the first error fn returns, which IterateEvents returns unless it is
+// StopIteration.
+func (m *CalendarManager) IterateEvents(from, to time.Time, fn func(Event) error) error {
+	sources, err := m.LoadSources()
+	if err != nil {
+		return err
+	}
+	now := m.Now()
+	for _, s := range sources {
+		var fnErr error
+		// Unreadable calendars are skipped, as in ListEvents; only errors
+		// from fn end the iteration.
+		m.Store.WalkEvents(s.Name, func(data []byte) error {
+			group, err := readEventGroup(data, s.Name, m.Config.Location())
+			if err != nil {
+				return nil
+			}
+			for _, e := range expandRecurrences(group, from, to, now) {
+				if !startsIn(e, from, to) {
+					continue
+				}
+				e.Color = s.Color
+				if fnErr = fn(e); fnErr != nil {
+					return fnErr
+				}
+			}
+			return nil
+		})
+		if errors.Is(fnErr, StopIteration) {
+			return nil
+		}
+		if fnErr != nil {
+			return fnErr
+		}
+	}
+	return nil
+}
+
+// startsIn reports whether e starts in [from, to), where a zero bound
+// leaves that side open.
+func startsIn(e Event, from, to time.Time) bool {
+	if !from.IsZero() && e.Start.Before(from) {
+		return false
+	}
+	return to.IsZero() || e.Start.Before(to)
+}
+
+// SortEvents orders events by start time, breaking ties by summary and
+// then UID so the order is the same on every run.
+func SortEvents(events []Event) {
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := events[i], events[j]
+		if !a.Start.Equal(b.Start) {
+			return a.Start.Before(b.Start)
+		}
+		if a.Summary != b.Summary {
+			return a.Summary < b.Summary
+		}
+		return a.UID < b.UID
+	})
+}
+
+// SortEventsByPriority orders events from highest to lowest priority, with
+// undefined priorities last. Events of equal priority keep their order.
+func SortEventsByPriority(events []Event) {
+	rank := func(prio int) int {
+		if prio == 0 {
+			return 10
+		}
+		return prio
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return rank(events[i].Priority) < rank(events[j].Priority)
+	})
+}
+
+// SortEventsByDistance orders events by great-circle distance from lat,
+// lon, nearest first. Events without GEO coordinates go last, keeping their
+// order.
+func SortEventsByDistance(events []Event, lat, lon float64) {
+	sort.SliceStable(events, func(i, j int) bool {
+		a, b := &events[i], &events[j]
+		if !a.HasGeo() || !b.HasGeo() {
+			return a.HasGeo() && !b.HasGeo()
+		}
+		return haversine(lat, lon, a.Lat, a.Lon) < haversine(lat, lon, b.Lat, b.Lon)
+	})
+}
+
+// earthRadiusKm is the mean radius of the Earth.
+const earthRadiusKm = 6371.0
+
+// haversine returns the great-circle distance in kilometres between two
+// points given in degrees.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat, dLon := rad(lat2-lat1), rad(lon2-lon1)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(lat1))*math.Cos(rad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// ParseLatLon parses a "lat,lon" pair in degrees, such as "37.77,-122.42".
+func ParseLatLon(s string) (lat, lon float64, err error) {
+	latStr, lonStr, found := strings.Cut(s, ",")
+	if found {
+		lat, err = strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+		if err == nil {
+			lon, err = strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+		}
+	}
+	if !found || err != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, fmt.Errorf("invalid coordinates %q (use lat,lon in degrees, e.g. 37.77,-122.42)", s)
+	}
+	return lat, lon, nil
+}
+
+// NextEvents returns up to n events starting at or after now.
+func (m *CalendarManager) NextEvents(now time.Time, n int) ([]Event, error) {
+	events, err := m.ListEvents(now, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > n {
+		events = events[:n]
+	}
+	return events, nil
+}
+
+// loadCalendarEvents returns every stored event of calName, from the
+// parsed cache when it is enabled and up to date.
+func (m *CalendarManager) loadCalendarEvents(calName string) ([]Event, error) {
+	if events, ok := m.cachedEvents(calName); ok {
+		return events, nil
+	}
+	return m.parseCalendarEvents(calName)
+}
+
+// parseCalendarEvents parses every stored event of calName, skipping files
+// that can't be read.
+func (m *CalendarManager) parseCalendarEvents(calName string) ([]Event, error) {
+	raws, err := m.Store.LoadEvents(calName)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, data := range raws {
+		group, err := readEventGroup(data, calName, m.Config.Location())
+		if err != nil {
+			continue
+		}
+		events = append(events, group...)
+	}
+	return events, nil
+}
+
+// readEvent decodes the master event in data, or its first event if it only
+// holds RECURRENCE-ID overrides. Floating times are interpreted in the
+// calendar's X-WR-TIMEZONE, or loc if it has none.
+func readEvent(data []byte, calName string, loc *time.Location) (*Event, error) {
+	group, err := readEventGroup(data, calName, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &group[0], nil
+}
+
+// readEventGroup decodes every event in data, master first and then any
+// RECURRENCE-ID overrides.
+func readEventGroup(data []byte, calName string, loc *time.Location) ([]Event, error) {
+	dec := ical.NewDecoder(strings.NewReader(string(data)))
+	cal, err := dec.Decode()
+	if err != nil {
+		return nil, err
+	}
+	loc = calendarLocation(cal, loc)
+
+	icalEvents := cal.Events()
+	if len(icalEvents) == 0 {
+		return nil, fmt.Errorf("no events in file")
+	}
+
+	var group []Event
+	for i := range icalEvents {
+		e := *parseEvent(&icalEvents[i], calName, loc)
+		if e.RecurrenceID.IsZero() {
+			group = append([]Event{e}, group...)
+		} else {
+			group = append(group, e)
+		}
+	}
+	return group, nil
+}
+
+// calendarLocation returns the zone named by the calendar's X-WR-TIMEZONE
+// property, or fallback if it is missing or unknown.
+func calendarLocation(cal *ical.Calendar, fallback *time.Location) *time.Location {
+	tz, err := cal.Props.Text("X-WR-TIMEZONE")
+	if err != nil || tz == "" {
+		return fallback
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return fallback
+	}
+	return loc
+}
+
+// parseEvent converts a decoded VEVENT into an Event, interpreting floating
+// times in loc.
+func parseEvent(ie *ical.Event, calName string, loc *time.Location) *Event {
+	uid, _ := ie.Props.Text(ical.PropUID)
+	summary, _ := ie.Props.Text(ical.PropSummary)
+	description, _ := ie.Props.Text(ical.PropDescription)
+	location, _ := ie.Props.Text(ical.PropLocation)
+	url, _ := ie.Props.Text(ical.PropURL)
+	status, _ := ie.Props.Text(ical.PropStatus)
+	var recurrence string
+	if p := ie.Props.Get(ical.PropRecurrenceRule); p != nil {
+		recurrence = p.Value
+	}
+
+	start, allDay := parseEventTime(ie, ical.PropDateTimeStart, loc)
+	end, _ := parseEventTime(ie, ical.PropDateTimeEnd, loc)
+	created, _ := ie.Props.DateTime(ical.PropCreated, time.UTC)
+	modified, _ := ie.Props.DateTime(ical.PropLastModified, time.UTC)
+	stamp, _ := ie.Props.DateTime(ical.PropDateTimeStamp, time.UTC)
+	recurrenceID, _ := parseEventTime(ie, ical.PropRecurrenceID, loc)
+	lat, lon, _ := eventGeo(ie)
+
+	return &Event{
+		UID:          uid,
+		Summary:      summary,
+		Description:  description,
+		Location:     location,
+		URL:          url,
+		Status:       strings.ToUpper(status),
+		Start:        start,
+		End:          end,
+		Calendar:     calName,
+		AllDay:       allDay,
+		Recurrence:   recurrence,
+		Created:      created,
+		Modified:     modified,
+		Stamp:        stamp,
+		Sequence:     eventSequence(ie),
+		Priority:     eventPriority(ie),
+		Attachments:  eventAttachments(ie),
+		Categories:   eventCategories(ie),
+		Attendees:    eventAttendees(ie),
+		Lat:          lat,
+		Lon:          lon,
+		RecurrenceID: recurrenceID,
+		exdates:      eventDates(ie, ical.PropExceptionDates, loc),
+		rdates:       eventDates(ie, ical.PropRecurrenceDates, loc),
+	}
+}
+
+// eventPriority returns the event's PRIORITY, or 0 if it is missing or out
+// of range.
+func eventPriority(ie *ical.Event) int {
+	p := ie.Props.Get(ical.PropPriority)
+	if p == nil {
+		return 0
+	}
+	prio, err := p.Int()
+	if err != nil || prio < 0 || prio > 9 {
+		return 0
+	}
+	return prio
+}
+
+// PriorityLabel describes a PRIORITY value as "high" (1-4), "normal" (5) or
+// "low" (6-9). Undefined priorities return "".
+func PriorityLabel(prio int) string {
+	switch {
+	case prio >= 1 && prio <= 4:
+		return "high"
+	case prio == 5:
+		return "normal"
+	case prio >= 6 && prio <= 9:
+		return "low"
+	}
+	return ""
+}
+
+// eventAttachments returns the URI values of the event's ATTACH properties.
+// Inline (base64) attachments are skipped.
+func eventAttachments(ie *ical.Event) []string {
+	var attachments []string
+	for _, p := range ie.Props.Values(ical.PropAttach) {
+		encoding, _ := paramValue(&p, "ENCODING")
+		valueType, _ := paramValue(&p, ical.ParamValue)
+		if strings.EqualFold(encoding, "BASE64") || strings.EqualFold(valueType, string(ical.ValueBinary)) {
+			continue
+		}
+		if p.Value != "" {
+			attachments = append(attachments, p.Value)
+		}
+	}
+	return attachments
+}
+
+// eventCategories returns the values of the event's CATEGORIES properties,
+// which may each hold a comma-separated list.
+func eventCategories(ie *ical.Event) []string {
+	var categories []string
+	for _, p := range ie.Props.Values(ical.PropCategories) {
+		values, err := p.TextList()
+		if err != nil {
+			continue
+		}
+		for _, v := range values {
+			if v = strings.TrimSpace(v); v != "" {
+				categories = append(categories, v)
+			}
+		}
+	}
+	return categories
+}
+
+// eventGeo parses the event's GEO property, "latitude;longitude" in
+// degrees. It reports false if the property is missing or out of range.
+func eventGeo(ie *ical.Event) (lat, lon float64, ok bool) {
+	p := ie.Props.Get(ical.PropGeo)
+	if p == nil {
+		return 0, 0, false
+	}
+	latStr, lonStr, found := strings.Cut(p.Value, ";")
+	if !found {
+		return 0, 0, false
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+	if err1 != nil || err2 != nil || lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// HasGeo reports whether the event has GEO coordinates. An event placed
+// exactly at 0, 0 is treated as having none.
+func (e *Event) HasGeo() bool {
+	return e.Lat != 0 || e.Lon != 0
+}
+
+// Attendee is an event participant from an ATTENDEE property.
+type Attendee struct {
+	Email string
+	Name  string `json:",omitempty"`
+	// PartStat is the upper-case PARTSTAT, such as ACCEPTED or DECLINED.
+	PartStat string
+}
+
+// String returns the attendee as "Name <email>", or just the email when
+// there is no name.
+func (a Attendee) String() string {
+	if a.Name == "" {
+		return a.Email
+	}
+	return a.Name + " <" + a.Email + ">"
+}
+
+// eventAttendees returns the event's attendees. A missing PARTSTAT is
+// NEEDS-ACTION, as RFC 5545 specifies.
+func eventAttendees(ie *ical.Event) []Attendee {
+	var attendees []Attendee
+	for _, p := range ie.Props.Values(ical.PropAttendee) {
+		email := p.Value
+		if len(email) >= 7 && strings.EqualFold(email[:7], "mailto:") {
+			email = email[7:]
+		}
+		if email == "" {
+			continue
+		}
+		partstat, _ := paramValue(&p, ical.ParamParticipationStatus)
+		partstat = strings.ToUpper(partstat)
+		name, _ := paramValue(&p, ical.ParamCommonName)
+		if partstat == "" {
+			partstat = "NEEDS-ACTION"
+		}
+		attendees = append(attendees, Attendee{Email: email, Name: name, PartStat: partstat})
+	}
+	return attendees
+}
+
+// eventSequence returns the event's SEQUENCE, or 0 if it has none.
+func eventSequence(ie *ical.Event) int {
+	p := ie.Props.Get(ical.PropSequence)
+	if p == nil {
+		return 0
+	}
+	seq, err := p.Int()
+	if err != nil {
+		return 0
+	}
+	return seq
+}
+
+// parseEventTime reads a date or date-time property. Values with a TZID use
+// that zone, UTC values ending in Z stay UTC, and floating values fall back
+// to the given location.
+func parseEventTime(event *ical.Event, prop string, fallback *time.Location) (time.Time, bool) {
+	p := event.Props.Get(prop)
+	if p == nil {
+		return time.Time{}, false
+	}
+	return parsePropTime(p, fallback)
+}
+
+// eventDate is one value of a date list property such as EXDATE.
+type eventDate struct {
+	t      time.Time
+	allDay bool
+}
+
+// eventDates reads every value of a date list property, which may repeat
+// and hold comma-separated values. PERIOD values contribute their start.
+func eventDates(event *ical.Event, prop string, fallback *time.Location) []eventDate {
+	var dates []eventDate
+	for _, p := range event.Props.Values(prop) {
+		for _, v := range strings.Split(p.Value, ",") {
+			v, _, _ = strings.Cut(v, "/")
+			single := p
+			single.Value = strings.TrimSpace(v)
+			if t, allDay := parsePropTime(&single, fallback); !t.IsZero() {
+				dates = append(dates, eventDate{t: t, allDay: allDay})
+			}
+		}
+	}
+	return dates
+}
+
+// paramValue returns the first value of p's parameter name, matching the
+// name case-insensitively and trimming stray whitespace and quotes that some
+// feeds leave around values. It reports false if the parameter is missing
+// or empty.
+func paramValue(p *ical.Prop, name string) (string, bool) {
+	for key, values := range p.Params {
+		if !strings.EqualFold(key, name) || len(values) == 0 {
+			continue
+		}
+		v := strings.Trim(strings.TrimSpace(values[0]), `"`)
+		return v, v != ""
+	}
+	return "", false
+}
+
+// parsePropTime parses a single date or date-time property value.
+func parsePropTime(p *ical.Prop, fallback *time.Location) (time.Time, bool) {
+
+	// Check if it's an all-day event (VALUE=DATE, in any case)
+	valueType, _ := paramValue(p, ical.ParamValue)
+	allDay := strings.EqualFold(valueType, string(ical.ValueDate))
+
+	// Try to resolve timezone from TZID parameter. A trailing Z marks an
+	// absolute UTC time, which wins over any TZID or fallback zone.
+	loc := fallback
+	if tzid, ok := paramValue(p, ical.ParamTimezoneID); ok {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	if strings.HasSuffix(p.Value, "Z") {
+		loc = time.UTC
+	}
+
+	// All-day dates are midnight in the fallback zone so they line up with
+	// day ranges built there rather than with UTC.
+	if allDay {
+		t, err := time.ParseInLocation("20060102", p.Value, loc)
+		if err != nil {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+
+	if t, err := p.DateTime(loc); err == nil {
+		// time.Parse accepts fractional seconds the layout doesn't have;
+		// drop them as parseLooseDateTime does.
+		return t.Truncate(time.Second), false
+	}
+	if t, ok := parseLooseDateTime(p.Value, loc); ok {
+		return t, false
+	}
+	// Fallback: try parsing as date only
+	if t, err := time.ParseInLocation("20060102", p.Value, loc); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// timeWarnings describes the date-time properties of the stored event data
+// that parsePropTime can't make sense of, which leave the event without a
+// start or end.
+func timeWarnings(data []byte) []string {
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil
+	}
+	var warnings []string
+	for _, ie := range cal.Events() {
+		for _, name := range []string{ical.PropDateTimeStart, ical.PropDateTimeEnd, ical.PropRecurrenceID} {
+			p := ie.Props.Get(name)
+			if p == nil {
 				continue
 			}
-			path := filepath.Join(dir, entry.Name())
-			event, err := readEvent(path, s.Name)
+			if t, _ := parsePropTime(p, time.UTC); t.IsZero() {
+				warnings = append(warnings, fmt.Sprintf("unrecognized %s value %q", p.Name, p.Value))
+			}
+		}
+	}
+	return warnings
+}
+
+// looseDateTimeLayouts are tried, in order, for date-times that aren't in
+// the strict RFC 5545 form, such as values missing seconds.
+var looseDateTimeLayouts = []string{
+	"20060102T150405",
+	"20060102T1504",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+// parseLooseDateTime parses non-conforming date-times, dropping fractional
+// seconds and honoring a trailing Z.
+func parseLooseDateTime(v string, loc *time.Location) (time.Time, bool) {
+	v = strings.TrimSpace(v)
+	if rest, ok := strings.CutSuffix(v, "Z"); ok {
+		v, loc = rest, time.UTC
+	}
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		v = v[:i]
+	}
+	for _, layout := range looseDateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// GetEventICS returns an event by UID as ICS, as EventICS formats it.
+func (m *CalendarManager) GetEventICS(uid string) (string, error) {
+	e, _, err := m.GetEvent(uid)
+	if err != nil {
+		return "", err
+	}
+	return m.EventICS(*e)
+}
+
+// GetEvent finds an event by UID across all calendars. The raw data always
+// has CRLF line endings, even if the stored file predates them.
+func (m *CalendarManager) GetEvent(uid string) (*Event, string, error) {
+	sources, err := m.LoadSources()
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, s := range sources {
+		raws, _ := m.Store.LoadEvents(s.Name)
+		for _, data := range raws {
+			event, err := readEvent(data, s.Name, m.Config.Location())
 			if err != nil {
 				continue
 			}
 			if event.UID == uid {
-				raw, _ := os.ReadFile(path)
-				return event, string(raw), nil
+				event.Color = s.Color
+				return event, normalizeCRLF(string(data)), nil
 			}
 		}
 	}
 	return nil, "", fmt.Errorf("event %q not found", uid)
 }
 
+// AmbiguousEventError is returned by ResolveEvent when a query matches more
+// than one event.
+type AmbiguousEventError struct {
+	Query      string
+	Candidates []Event
+}
+
+func (e *AmbiguousEventError) Error() string {
+	return fmt.Sprintf("%q matches %d events; use a UID", e.Query, len(e.Candidates))
+}
+
+// ResolveEvent finds an event by exact UID or, failing that, by a
+// case-insensitive substring of its summary that matches exactly one event.
+func (m *CalendarManager) ResolveEvent(query string) (*Event, string, error) {
+	event, raw, err := m.GetEvent(query)
+	if err == nil {
+		return event, raw, nil
+	}
+	events, err := m.StoredEvents()
+	if err != nil {
+		return nil, "", err
+	}
+	q := strings.ToLower(query)
+	var matches []Event
+	for _, e := range events {
+		if strings.Contains(strings.ToLower(e.Summary), q) {
+			matches = append(matches, e)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, "", fmt.Errorf("no event with UID or summary matching %q", query)
+	case 1:
+		return m.GetEvent(matches[0].UID)
+	default:
+		return nil, "", &AmbiguousEventError{Query: query, Candidates: matches}
+	}
+}
+
+// StoredEvents returns the master of every stored event in all calendars,
+// without expanding recurrences, sorted by start.
+func (m *CalendarManager) StoredEvents() ([]Event, error) {
+	sources, err := m.LoadSources()
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for _, s := range sources {
+		raws, _ := m.Store.LoadEvents(s.Name)
+		for _, data := range raws {
+			event, err := readEvent(data, s.Name, m.Config.Location())
+			if err != nil {
+				continue
+			}
+			event.Color = s.Color
+			events = append(events, *event)
+		}
+	}
+	SortEvents(events)
+	return events, nil
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// MeetingURL returns the event's URL property, or failing that the first
+// http(s) link in its location or description.
+func MeetingURL(e *Event) string {
+	if e.URL != "" {
+		return e.URL
+	}
+	for _, text := range []string{e.Location, e.Description} {
+		if u := urlPattern.FindString(text); u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+// DisplaySummary returns the event's summary for human-readable output,
+// falling back to the first line of its description or "(no title)".
+func DisplaySummary(e *Event) string {
+	if s := strings.TrimSpace(e.Summary); s != "" {
+		return s
+	}
+	line, _, _ := strings.Cut(strings.TrimSpace(e.Description), "\n")
+	if line = strings.TrimSpace(line); line != "" {
+		return line
+	}
+	return "(no title)"
+}
+
+// Layouts are Go time layouts for displaying dates and times of day. An
+// empty field keeps the renderer's default.
+type Layouts struct {
+	Date string
+	Time string
+}
+
+// Or returns l with empty fields taken from def.
+func (l Layouts) Or(def Layouts) Layouts {
+	if l.Date == "" {
+		l.Date = def.Date
+	}
+	if l.Time == "" {
+		l.Time = def.Time
+	}
+	return l
+}
+
 // FormatEvent returns a human-readable representation of an event.
 func FormatEvent(e *Event) string {
+	return FormatEventLayouts(e, Layouts{})
+}
+
+// FormatEventLayouts is FormatEvent with dates and times shown using l.
+func FormatEventLayouts(e *Event, l Layouts) string {
+	l = l.Or(Layouts{Date: "Mon, 02 Jan 2006", Time: "15:04"})
+	dateTime := l.Date + " " + l.Time + " MST"
 	var b strings.Builder
-	fmt.Fprintf(&b, "Summary:     %s\n", e.Summary)
+	fmt.Fprintf(&b, "Summary:     %s\n", DisplaySummary(e))
 	fmt.Fprintf(&b, "Calendar:    %s\n", e.Calendar)
 	if e.AllDay {
-		fmt.Fprintf(&b, "Date:        %s\n", e.Start.Format("Mon, 02 Jan 2006"))
+		fmt.Fprintf(&b, "Date:        %s\n", e.Start.Format(l.Date))
 		if !e.End.IsZero() && !e.End.Equal(e.Start) {
-			fmt.Fprintf(&b, "End:         %s\n", e.End.Format("Mon, 02 Jan 2006"))
+			fmt.Fprintf(&b, "End:         %s\n", e.End.Format(l.Date))
 		}
 	} else {
-		fmt.Fprintf(&b, "Start:       %s\n", e.Start.Format("Mon, 02 Jan 2006 15:04 MST"))
+		fmt.Fprintf(&b, "Start:       %s\n", e.Start.Format(dateTime))
 		if !e.End.IsZero() {
-			fmt.Fprintf(&b, "End:         %s\n", e.End.Format("Mon, 02 Jan 2006 15:04 MST"))
+			fmt.Fprintf(&b, "End:         %s\n", e.End.Format(dateTime))
 		}
 	}
+	if e.Status != "" {
+		fmt.Fprintf(&b, "Status:      %s\n", e.Status)
+	}
+	if e.Priority != 0 {
+		fmt.Fprintf(&b, "Priority:    %s (%d)\n", PriorityLabel(e.Priority), e.Priority)
+	}
+	if e.Recurrence != "" {
+		fmt.Fprintf(&b, "Recurrence:  %s\n", describeRRULE(e.Recurrence))
+	}
 	if e.Location != "" {
 		fmt.Fprintf(&b, "Location:    %s\n", e.Location)
 	}
+	if e.HasGeo() {
+		fmt.Fprintf(&b, "Geo:         %g, %g\n", e.Lat, e.Lon)
+	}
+	if e.URL != "" {
+		fmt.Fprintf(&b, "URL:         %s\n", e.URL)
+	}
+	if len(e.Categories) > 0 {
+		fmt.Fprintf(&b, "Categories:  %s\n", strings.Join(e.Categories, ", "))
+	}
 	if e.Description != "" {
 		fmt.Fprintf(&b, "Description: %s\n", e.Description)
 	}
+	for _, a := range e.Attendees {
+		fmt.Fprintf(&b, "Attendee:    %s (%s)\n", a, strings.ToLower(a.PartStat))
+	}
+	for _, a := range e.Attachments {
+		fmt.Fprintf(&b, "Attachment:  %s\n", a)
+	}
+	if !e.Modified.IsZero() {
+		// Shown in the event's own zone, like Start and End, rather than
+		// the host's.
+		modified := e.Modified
+		if !e.Start.IsZero() {
+			modified = modified.In(e.Start.Location())
+		}
+		fmt.Fprintf(&b, "Modified:    %s\n", modified.Format(dateTime))
+	}
+	if e.Sequence > 0 {
+		fmt.Fprintf(&b, "Sequence:    %d\n", e.Sequence)
+	}
 	fmt.Fprintf(&b, "UID:         %s\n", e.UID)
 	return b.String()
 }
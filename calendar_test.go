@@ -0,0 +1,685 @@
+package calendar
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// newTestManager returns a manager rooted in a temporary directory with
+// logging discarded.
+func newTestManager(t *testing.T) *CalendarManager {
+	t.Helper()
+	cfg, err := LoadConfig(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewCalendarManagerWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Log.Out = io.Discard
+	m.Log.Err = io.Discard
+	return m
+}
+
+// serveICS serves body as a calendar feed for the duration of the test.
+func serveICS(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+const testFeed = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+	"BEGIN:VEVENT\r\nUID:one\r\nDTSTAMP:20260101T000000Z\r\nDTSTART:20260105T100000Z\r\nSUMMARY:One\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestSyncQuarantinesUnreadableEvents(t *testing.T) {
+	m := newTestManager(t)
+	srv := serveICS(t, testFeed)
+	if err := m.AddSource(Source{Name: "feed", URL: srv.URL}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	dir := m.Config.CalendarDir("feed")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	junk := filepath.Join(dir, "junk.ics")
+	if err := os.WriteFile(junk, []byte("not a calendar"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.SyncAll(context.Background(), SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(junk); !os.IsNotExist(err) {
+		t.Errorf("unreadable event left in the calendar")
+	}
+	if _, err := os.Stat(filepath.Join(dir, quarantineDir, "junk.ics")); err != nil {
+		t.Errorf("unreadable event not quarantined: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "one.ics")); err != nil {
+		t.Errorf("synced event missing: %v", err)
+	}
+}
+
+func TestSyncTimesOutHungFeed(t *testing.T) {
+	cfg, err := LoadConfig(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.FetchTimeout = 1
+	m, err := NewCalendarManagerWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Log.Out = io.Discard
+	m.Log.Err = io.Discard
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	if err := m.AddSource(Source{Name: "hung", URL: srv.URL}, AddOptions{SkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if _, err := m.SyncAll(context.Background(), SyncOptions{}); err == nil {
+		t.Fatal("sync of a hung feed succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("sync took %s despite a 1s timeout", elapsed)
+	}
+}
+
+func TestSuggestSourceNameReadsOnlyHeader(t *testing.T) {
+	m := newTestManager(t)
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/calendar")
+		io.WriteString(w, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nX-WR-CALNAME:Team\\, Ops\r\nBEGIN:VEVENT\r\n")
+		w.(http.Flusher).Flush()
+		// Never finish the feed; the name is already known.
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	done := make(chan string)
+	go func() { done <- m.SuggestSourceName(srv.URL) }()
+	select {
+	case name := <-done:
+		if name != "Team, Ops" {
+			t.Errorf("SuggestSourceName = %q, want %q", name, "Team, Ops")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("SuggestSourceName waited for the whole feed")
+	}
+}
+
+func TestSuggestSourceNameTimesOut(t *testing.T) {
+	m := newTestManager(t)
+	defer func(d time.Duration) { suggestNameTimeout = d }(suggestNameTimeout)
+	suggestNameTimeout = 100 * time.Millisecond
+	release := make(chan struct{})
+	defer close(release)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	name := m.SuggestSourceName(srv.URL)
+	if name != "127.0.0.1" {
+		t.Errorf("SuggestSourceName = %q, want the host", name)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("SuggestSourceName took %s", d)
+	}
+}
+
+func TestParsePropTimeUTC(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	want := time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name     string
+		value    string
+		params   ical.Params
+		fallback *time.Location
+	}{
+		{"Z with UTC fallback", "20240110T150000Z", nil, time.UTC},
+		{"Z with other fallback", "20240110T150000Z", nil, ny},
+		{"Z wins over TZID", "20240110T150000Z", ical.Params{ical.ParamTimezoneID: {"Asia/Tokyo"}}, ny},
+		{"equivalent TZID", "20240110T100000", ical.Params{ical.ParamTimezoneID: {"America/New_York"}}, time.UTC},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &ical.Prop{Name: ical.PropDateTimeStart, Value: tc.value, Params: tc.params}
+			got, allDay := parsePropTime(p, tc.fallback)
+			if allDay || !got.Equal(want) {
+				t.Errorf("parsePropTime(%q) = %v, %v; want %v", tc.value, got, allDay, want)
+			}
+			if strings.HasSuffix(tc.value, "Z") && got.Location() != time.UTC {
+				t.Errorf("location = %v, want UTC", got.Location())
+			}
+		})
+	}
+}
+
+func TestSyncOddlyWrittenParams(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:holiday\r\nDTSTAMP:20260101T000000Z\r\nDTSTART;value=date:20260110\r\nDTEND;value=date:20260111\r\nSUMMARY:Holiday\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:meeting\r\nDTSTAMP:20260101T000000Z\r\nDTSTART;TZID=\"America/New_York\":20260112T100000\r\nDTEND;TZID=\"America/New_York\":20260112T110000\r\nSUMMARY:Meeting\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	m := newTestManager(t)
+	syncFeed(t, m, feed)
+
+	holiday, _, err := m.GetEvent("holiday")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !holiday.AllDay || holiday.Start.Format("2006-01-02") != "2026-01-10" {
+		t.Errorf("lowercase value=date: start %v, all-day %v; want all-day 2026-01-10", holiday.Start, holiday.AllDay)
+	}
+	meeting, _, err := m.GetEvent("meeting")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 1, 12, 10, 0, 0, 0, ny); meeting.AllDay || !meeting.Start.Equal(want) {
+		t.Errorf("quoted TZID: start %v, all-day %v; want %v", meeting.Start, meeting.AllDay, want)
+	}
+}
+
+func TestParseMalformedDTSTART(t *testing.T) {
+	for _, tc := range []struct {
+		dtstart string
+		want    time.Time
+		allDay  bool
+	}{
+		{"DTSTART:20240110T1500", time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC), false},
+		{"DTSTART:20240110T1500Z", time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC), false},
+		{"DTSTART:20240110T150000.250Z", time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC), false},
+		{"DTSTART:20240110T150000.250", time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC), false},
+		{"DTSTART:2024-01-10T15:00:00", time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC), false},
+		{"DTSTART:2024-01-10T15:00Z", time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC), false},
+		{"DTSTART;TZID=America/New_York:20240110T1000", time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC), false},
+		{"DTSTART:20240110", time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC), true},
+	} {
+		t.Run(tc.dtstart, func(t *testing.T) {
+			feed := strings.Replace(testFeed, "DTSTART:20260105T100000Z", tc.dtstart, 1)
+			cal, err := ical.NewDecoder(strings.NewReader(feed)).Decode()
+			if err != nil {
+				t.Fatal(err)
+			}
+			events := calendarEvents(cal, "feed", time.UTC)
+			if len(events) != 1 {
+				t.Fatalf("got %d events, want 1", len(events))
+			}
+			if e := events[0]; !e.Start.Equal(tc.want) || e.AllDay != tc.allDay {
+				t.Errorf("Start = %v (all day %v), want %v (all day %v)", e.Start, e.AllDay, tc.want, tc.allDay)
+			}
+		})
+	}
+}
+
+func TestSyncWarnsOnceAboutBadDTSTART(t *testing.T) {
+	m := newTestManager(t)
+	var errOut strings.Builder
+	m.Log.Err = &errOut
+	srv := serveICS(t, strings.Replace(testFeed, "DTSTART:20260105T100000Z", "DTSTART:next tuesday", 1))
+	if err := m.AddSource(Source{Name: "feed", URL: srv.URL}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := m.SyncAll(context.Background(), SyncOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.ListEvents(time.Time{}, time.Time{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := "  warning: one: unrecognized DTSTART value \"next tuesday\"\n"
+	if errOut.String() != want {
+		t.Errorf("warnings = %q, want %q once", errOut.String(), want)
+	}
+}
+
+func TestSortEventsSameStart(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	want := []Event{
+		{UID: "c", Summary: "Alpha", Start: start},
+		{UID: "a", Summary: "Beta", Start: start},
+		{UID: "b", Summary: "Beta", Start: start},
+		{UID: "d", Summary: "Alpha", Start: start.Add(time.Hour)},
+	}
+	for _, order := range [][]int{{0, 1, 2, 3}, {3, 2, 1, 0}, {2, 0, 3, 1}, {1, 3, 0, 2}} {
+		events := make([]Event, len(order))
+		for i, j := range order {
+			events[i] = want[j]
+		}
+		SortEvents(events)
+		for i := range events {
+			if events[i].UID != want[i].UID {
+				t.Errorf("from order %v: position %d is %s, want %s", order, i, events[i].UID, want[i].UID)
+			}
+		}
+	}
+}
+
+func TestListEventsSameStartIsStable(t *testing.T) {
+	var feed strings.Builder
+	feed.WriteString("BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n")
+	for _, uid := range []string{"e", "b", "d", "a", "c"} {
+		feed.WriteString("BEGIN:VEVENT\r\nUID:" + uid + "\r\nDTSTAMP:20260101T000000Z\r\nDTSTART:20260105T100000Z\r\nSUMMARY:Same\r\nEND:VEVENT\r\n")
+	}
+	feed.WriteString("END:VCALENDAR\r\n")
+	m := newTestManager(t)
+	syncFeed(t, m, feed.String())
+
+	for range 5 {
+		events, err := m.ListEvents(time.Time{}, time.Time{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var uids []string
+		for _, e := range events {
+			uids = append(uids, e.UID)
+		}
+		if got := strings.Join(uids, ","); got != "a,b,c,d,e" {
+			t.Fatalf("order = %s, want a,b,c,d,e", got)
+		}
+	}
+}
+
+func TestNeverSyncedSource(t *testing.T) {
+	m := newTestManager(t)
+	if err := m.AddSource(Source{Name: "feed", URL: "https://example.com/feed.ics"}, AddOptions{SkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(m.Config.CalendarDir("feed")); !os.IsNotExist(err) {
+		t.Fatalf("calendar directory exists before syncing: %v", err)
+	}
+
+	events, err := m.ListEvents(time.Time{}, time.Time{})
+	if err != nil || len(events) != 0 {
+		t.Errorf("ListEvents = %d events, %v; want none and no error", len(events), err)
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statuses) != 1 || !statuses[0].LastSync.IsZero() || statuses[0].EventCount != 0 {
+		t.Errorf("Status = %+v, want one never-synced source without events", statuses)
+	}
+	if _, _, err := m.GetEvent("one"); err == nil || err.Error() != `event "one" not found` {
+		t.Errorf("GetEvent err = %v, want not found", err)
+	}
+}
+
+func TestSyncKeepsEventsOfFailedFeed(t *testing.T) {
+	m := newTestManager(t)
+	good := serveICS(t, testFeed)
+	var failing atomic.Bool
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		io.WriteString(w, strings.NewReplacer("UID:one", "UID:two", "SUMMARY:One", "SUMMARY:Two").Replace(testFeed))
+	}))
+	defer other.Close()
+	src := Source{Name: "feed", URL: good.URL, URLs: []string{other.URL}}
+	if err := m.AddSource(src, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SyncAll(context.Background(), SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	failing.Store(true)
+	results, err := m.SyncAll(context.Background(), SyncOptions{})
+	if err == nil || !strings.Contains(err.Error(), other.URL) {
+		t.Errorf("err = %v, want the failed feed's URL", err)
+	}
+	if len(results) != 1 || len(results[0].FeedErrors) != 1 || results[0].FeedErrors[0].URL != other.URL {
+		t.Fatalf("results = %+v, want one feed error for %s", results, other.URL)
+	}
+	if res := results[0]; res.Removed != 0 || res.Unchanged != 2 {
+		t.Errorf("result = %+v, want both events kept", res)
+	}
+	for _, uid := range []string{"one", "two"} {
+		if _, _, err := m.GetEvent(uid); err != nil {
+			t.Errorf("event %s: %v", uid, err)
+		}
+	}
+	records, err := m.SyncHistory(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || !strings.Contains(records[0].Sources[0].Error, other.URL) {
+		t.Errorf("history = %+v, want the feed error recorded", records)
+	}
+
+	// With every feed down the source fails as a whole.
+	good.Close()
+	if _, err := m.SyncAll(context.Background(), SyncOptions{}); err == nil {
+		t.Error("SyncAll succeeded with every feed down")
+	}
+}
+
+func TestListEventsExclusiveEnd(t *testing.T) {
+	m := newTestManager(t)
+	to := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+	for summary, start := range map[string]time.Time{
+		"at to":        to,
+		"just before":  to.Add(-time.Second),
+		"at from":      to.AddDate(0, 0, -1),
+		"before from":  to.AddDate(0, 0, -1).Add(-time.Hour),
+		"day after to": to.AddDate(0, 0, 1),
+	} {
+		if _, err := m.CreateEvent("personal", Event{Summary: summary, Start: start, End: start}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	events, err := m.ListEvents(to.AddDate(0, 0, -1), to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range events {
+		got = append(got, e.Summary)
+	}
+	if strings.Join(got, ",") != "at from,just before" {
+		t.Errorf("events in [from, to) = %q, want at from and just before", got)
+	}
+}
+
+func TestSetSourceEnabled(t *testing.T) {
+	m := newTestManager(t)
+	srv := serveICS(t, testFeed)
+	if err := m.AddSource(Source{Name: "Feed", URL: srv.URL}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetSourceEnabled("feed", false); err != nil {
+		t.Fatal(err)
+	}
+	results, err := m.SyncAll(context.Background(), SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("disabled calendar synced: %+v", results)
+	}
+	statuses, err := m.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[0].Enabled {
+		t.Error("status shows the calendar enabled")
+	}
+
+	if err := m.SetSourceEnabled("FEED", true); err != nil {
+		t.Fatal(err)
+	}
+	if results, err := m.SyncAll(context.Background(), SyncOptions{}); err != nil || len(results) != 1 {
+		t.Errorf("SyncAll after enabling = %+v, %v", results, err)
+	}
+	if err := m.SetSourceEnabled("missing", false); err == nil {
+		t.Error("disabled a calendar that doesn't exist")
+	}
+}
+
+func TestTitlelessEventDisplay(t *testing.T) {
+	feed := strings.Replace(testFeed, "SUMMARY:One\r\n", "DESCRIPTION:Call with Sam\\nDial in at 10\r\n", 1)
+	m := newTestManager(t)
+	syncFeed(t, m, feed)
+	e, _, err := m.GetEvent("one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := DisplaySummary(e); got != "Call with Sam" {
+		t.Errorf("DisplaySummary = %q, want the description's first line", got)
+	}
+	if out := FormatEvent(e); !strings.Contains(out, "Summary:     Call with Sam\n") {
+		t.Errorf("FormatEvent doesn't show the fallback:\n%s", out)
+	}
+	data, err := FormatEventJSON(e, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(data, `"Summary":""`) {
+		t.Errorf("JSON summary isn't empty: %s", data)
+	}
+
+	e.Description = ""
+	if got := DisplaySummary(e); got != "(no title)" {
+		t.Errorf("DisplaySummary = %q, want (no title)", got)
+	}
+}
+
+func TestAllDayEventsInLargeOffsetZones(t *testing.T) {
+	feed := strings.Replace(testFeed, "DTSTART:20260105T100000Z", "DTSTART;VALUE=DATE:20261225", 1)
+	for _, zone := range []string{"Pacific/Kiritimati", "Pacific/Pago_Pago"} {
+		t.Run(zone, func(t *testing.T) {
+			loc, err := time.LoadLocation(zone)
+			if err != nil {
+				t.Skip(err)
+			}
+			t.Setenv("CALENDAR_TZ", zone)
+			m := newTestManager(t)
+			syncFeed(t, m, feed)
+			for day, want := range map[int]int{24: 0, 25: 1, 26: 0} {
+				from := time.Date(2026, 12, day, 0, 0, 0, 0, loc)
+				events, err := m.ListEvents(from, from.AddDate(0, 0, 1))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(events) != want {
+					t.Errorf("Dec %d: got %d events, want %d", day, len(events), want)
+				}
+			}
+			e, _, err := m.GetEvent("one")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if y, mo, d := e.Start.In(loc).Date(); y != 2026 || mo != 12 || d != 25 || e.Start.In(loc).Hour() != 0 {
+				t.Errorf("Start = %v, want midnight on Dec 25 in %s", e.Start, zone)
+			}
+		})
+	}
+}
+
+func TestMergeSourcesIgnoresCase(t *testing.T) {
+	m := newTestManager(t)
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	if _, err := m.CreateEvent("Home", Event{Summary: "Dinner", Start: start}); err != nil {
+		t.Fatal(err)
+	}
+	uid, err := m.CreateEvent("Family", Event{Summary: "Picnic", Start: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.MergeSources("home", []string{"FAMILY", "family"}); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := m.LoadSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0].Name != "Home" {
+		t.Errorf("sources = %+v, want only Home", sources)
+	}
+	e, _, err := m.GetEvent(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Calendar != "Home" {
+		t.Errorf("merged event is in %q, want Home", e.Calendar)
+	}
+	if _, err := os.Stat(m.Config.CalendarDir("Family")); !os.IsNotExist(err) {
+		t.Errorf("merged calendar's directory is still there: %v", err)
+	}
+	if err := m.MergeSources("HOME", []string{"home"}); err == nil || !strings.Contains(err.Error(), "into itself") {
+		t.Errorf("merging a calendar into itself by another case: err = %v", err)
+	}
+}
+
+// failingDeleteStore is a Store whose DeleteCalendar always fails.
+type failingDeleteStore struct {
+	Store
+}
+
+func (failingDeleteStore) DeleteCalendar(string) error {
+	return errors.New("disk on fire")
+}
+
+func TestMergeSourcesReturnsDeleteError(t *testing.T) {
+	m := newTestManager(t)
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	for _, cal := range []string{"home", "family"} {
+		if _, err := m.CreateEvent(cal, Event{Summary: "Dinner", Start: start}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Store = failingDeleteStore{m.Store}
+	if err := m.MergeSources("home", []string{"family"}); err == nil || !strings.Contains(err.Error(), "disk on fire") {
+		t.Errorf("err = %v, want DeleteCalendar's error", err)
+	}
+}
+
+// failingSaveStore is a Store that can't write the event with UID two.
+type failingSaveStore struct {
+	Store
+}
+
+func (s failingSaveStore) SaveEvent(calName, uid string, data []byte) error {
+	if uid == "two" {
+		return errors.New("no space left on device")
+	}
+	return s.Store.SaveEvent(calName, uid, data)
+}
+
+func TestSyncReportsFailedWrites(t *testing.T) {
+	feed := strings.Replace(testFeed, "END:VCALENDAR",
+		"BEGIN:VEVENT\r\nUID:two\r\nDTSTAMP:20260101T000000Z\r\nDTSTART:20260106T100000Z\r\nSUMMARY:Two\r\nEND:VEVENT\r\nEND:VCALENDAR", 1)
+	srv := serveICS(t, feed)
+	m := newTestManager(t)
+	if err := m.AddSource(Source{Name: "feed", URL: srv.URL}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	m.Store = failingSaveStore{m.Store}
+	_, err := m.SyncAll(context.Background(), SyncOptions{})
+	if err == nil || !strings.Contains(err.Error(), "saving two: no space left on device") {
+		t.Errorf("err = %v, want the failed save", err)
+	}
+	meta, err := m.Store.LoadMeta("feed")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.EventCount != 1 {
+		t.Errorf("EventCount = %d, want 1 for the one event saved", meta.EventCount)
+	}
+}
+
+func TestFindSourceCase(t *testing.T) {
+	m := newTestManager(t)
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	if _, err := m.CreateEvent("Work", Event{Summary: "Standup", Start: start}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RemoveSource("work"); err != nil {
+		t.Fatalf("RemoveSource ignoring case: %v", err)
+	}
+	if events, _ := m.ListEvents(time.Time{}, time.Time{}); len(events) != 0 {
+		t.Errorf("events of the removed calendar are left: %+v", events)
+	}
+
+	for _, name := range []string{"Work", "work"} {
+		src := Source{Name: name, URL: "https://example.com/" + name + ".ics"}
+		if err := m.AddSource(src, AddOptions{SkipVerify: true, CaseSensitive: true, AllowDuplicateURL: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.SetSourceEnabled("WORK", false); err == nil || !strings.Contains(err.Error(), "use the exact name") {
+		t.Errorf("ambiguous name: err = %v", err)
+	}
+	if err := m.RemoveSource("work"); err != nil {
+		t.Fatal(err)
+	}
+	sources, err := m.LoadSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0].Name != "Work" {
+		t.Errorf("sources = %+v, want only Work", sources)
+	}
+}
+
+func TestSyncSourceIfStale(t *testing.T) {
+	srv := serveICS(t, testFeed)
+	m := newTestManager(t)
+	if err := m.AddSource(Source{Name: "feed", URL: srv.URL, RefreshInterval: "1h"}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := m.SyncSource(context.Background(), "feed", SyncOptions{IfStale: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Fresh || res.Added != 1 {
+		t.Errorf("first sync = %+v, want the event added", res)
+	}
+	res, err = m.SyncSource(context.Background(), "feed", SyncOptions{IfStale: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Fresh || res.Changed() {
+		t.Errorf("second sync = %+v, want it skipped as fresh", res)
+	}
+}
+
+func TestFormatEventModifiedInEventZone(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	e := &Event{
+		UID:      "a",
+		Start:    time.Date(2026, 1, 5, 9, 0, 0, 0, ny),
+		Modified: time.Date(2026, 1, 2, 15, 30, 0, 0, time.UTC),
+	}
+	if out := FormatEvent(e); !strings.Contains(out, "Modified:    Fri, 02 Jan 2026 10:30 EST\n") {
+		t.Errorf("Modified not shown in the event's zone:\n%s", out)
+	}
+}
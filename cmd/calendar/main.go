@@ -1,8 +1,17 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -12,8 +21,8 @@ import (
 	"github.com/spf13/cobra"
 )
 
-func validCalendarNames(_ *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
-	mgr, err := calendar.NewCalendarManager()
+func validCalendarNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	mgr, err := newManager(cmd)
 	if err != nil {
 		return nil, cobra.ShellCompDirectiveNoFileComp
 	}
@@ -31,32 +40,168 @@ func validCalendarNames(_ *cobra.Command, args []string, toComplete string) ([]s
 	return names, cobra.ShellCompDirectiveNoFileComp
 }
 
+// uidCompleter completes event UIDs, described by their summaries. A word
+// that isn't a UID prefix also matches on a summary substring.
+func uidCompleter(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	mgr, err := newManager(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	events, err := mgr.StoredEvents()
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	q := strings.ToLower(toComplete)
+	var uids []string
+	for _, e := range events {
+		if strings.HasPrefix(e.UID, toComplete) || strings.Contains(strings.ToLower(e.Summary), q) {
+			uids = append(uids, e.UID+"\t"+calendar.DisplaySummary(&e))
+		}
+	}
+	return uids, cobra.ShellCompDirectiveNoFileComp
+}
+
+// envelope wraps JSON output in a {"schema": ..., key: ...} envelope when
+// --envelope is set, and returns it unchanged otherwise.
+func envelope(cmd *cobra.Command, key, data string, compact bool) (string, error) {
+	if on, _ := cmd.Flags().GetBool("envelope"); !on {
+		return data, nil
+	}
+	return calendar.WrapJSON(key, data, compact)
+}
+
+// outputWriter returns where a command should write its output: the file
+// named by --output-file (creating parent directories) or stdout. The close
+// function is safe to call more than once.
+func outputWriter(cmd *cobra.Command) (io.Writer, func() error, error) {
+	path, _ := cmd.Flags().GetString("output-file")
+	if path == "" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	closed := false
+	return f, func() error {
+		if closed {
+			return nil
+		}
+		closed = true
+		return f.Close()
+	}, nil
+}
+
+// baseDir returns the directory given by --dir, or the default base
+// directory that profiles live under.
+func baseDir(cmd *cobra.Command) (string, error) {
+	if dir, _ := cmd.Flags().GetString("dir"); dir != "" {
+		return dir, nil
+	}
+	return calendar.DefaultBaseDir()
+}
+
+// activeProfile returns the profile given by --profile, or the active
+// profile under base.
+func activeProfile(cmd *cobra.Command, base string) (string, error) {
+	if profile, _ := cmd.Flags().GetString("profile"); profile != "" {
+		return profile, nil
+	}
+	return calendar.ActiveProfile(base)
+}
+
+// newManager creates a CalendarManager configured from the global flags.
+func newManager(cmd *cobra.Command) (*calendar.CalendarManager, error) {
+	base, err := baseDir(cmd)
+	if err != nil {
+		return nil, err
+	}
+	profile, err := activeProfile(cmd, base)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := calendar.LoadProfileConfig(base, profile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Insecure, _ = cmd.Flags().GetBool("insecure")
+	mgr, err := calendar.NewCalendarManagerWithConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	switch {
+	case quiet && verbose:
+		return nil, fmt.Errorf("--quiet and --verbose are mutually exclusive")
+	case quiet:
+		mgr.Log.Level = calendar.LogQuiet
+	case verbose:
+		mgr.Log.Level = calendar.LogVerbose
+	}
+	return mgr, nil
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "calendar",
 	Short: "manage calendars and events",
 }
 
 var addCmd = &cobra.Command{
-	Use:   "add [name] [url]",
+	Use:   "add [name] [url...]",
 	Short: "add a calendar source by iCal URL",
-	Args:  cobra.MaximumNArgs(2),
+	Long: `add a calendar source by iCal URL
+
+If only a URL is given, the feed is fetched and its X-WR-CALNAME (or the
+URL's host) is suggested as the calendar name. Extra URLs are synced into
+the same calendar, for providers that split one calendar across feeds.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		var name, url string
+		var extra []string
 
-		if len(args) >= 2 {
+		switch {
+		case len(args) >= 2:
 			name = args[0]
 			url = args[1]
-		} else {
+			extra = args[2:]
+		case len(args) == 1 && strings.Contains(args[0], "://"):
+			url = args[0]
+		case len(args) == 1:
+			name = args[0]
+		}
+
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		if url == "" {
+			form := huh.NewForm(
+				huh.NewGroup(
+					huh.NewInput().
+						Title("iCal URL").
+						Description("The .ics or webcal URL for this calendar").
+						Value(&url),
+				),
+			)
+			if err := form.Run(); err != nil {
+				return err
+			}
+		}
+		if name == "" && url != "" {
+			name = mgr.SuggestSourceName(url)
 			form := huh.NewForm(
 				huh.NewGroup(
 					huh.NewInput().
 						Title("Calendar Name").
 						Description("A short name for this calendar").
 						Value(&name),
-					huh.NewInput().
-						Title("iCal URL").
-						Description("The .ics URL for this calendar").
-						Value(&url),
 				),
 			)
 			if err := form.Run(); err != nil {
@@ -68,11 +213,19 @@ var addCmd = &cobra.Command{
 			return fmt.Errorf("name and URL are required")
 		}
 
-		mgr, err := calendar.NewCalendarManager()
-		if err != nil {
-			return err
-		}
-		if err := mgr.AddSource(name, url); err != nil {
+		caseSensitive, _ := cmd.Flags().GetBool("case-sensitive")
+		noVerify, _ := cmd.Flags().GetBool("no-verify")
+		force, _ := cmd.Flags().GetBool("force")
+		opts := calendar.AddOptions{CaseSensitive: caseSensitive, SkipVerify: noVerify, AllowDuplicateURL: force}
+		refresh, _ := cmd.Flags().GetString("refresh")
+		src := calendar.Source{Name: name, URL: url, URLs: extra, RefreshInterval: refresh}
+		src.Type, _ = cmd.Flags().GetString("type")
+		src.Username, _ = cmd.Flags().GetString("username")
+		src.PasswordEnv, _ = cmd.Flags().GetString("password-env")
+		src.Color, _ = cmd.Flags().GetString("color")
+		// --insecure on add is remembered for the source's future syncs.
+		src.Insecure, _ = cmd.Flags().GetBool("insecure")
+		if err := mgr.AddSource(src, opts); err != nil {
 			return err
 		}
 		fmt.Printf("added calendar %q\n", name)
@@ -86,7 +239,7 @@ var removeCmd = &cobra.Command{
 	Args:              cobra.ExactArgs(1),
 	ValidArgsFunction: validCalendarNames,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		mgr, err := calendar.NewCalendarManager()
+		mgr, err := newManager(cmd)
 		if err != nil {
 			return err
 		}
@@ -98,93 +251,194 @@ var removeCmd = &cobra.Command{
 	},
 }
 
-var syncCmd = &cobra.Command{
-	Use:   "sync",
-	Short: "sync all calendars from their iCal URLs",
+var disableCmd = &cobra.Command{
+	Use:               "disable <name>",
+	Short:             "stop syncing a calendar, keeping its events",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validCalendarNames,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		mgr, err := calendar.NewCalendarManager()
+		mgr, err := newManager(cmd)
 		if err != nil {
 			return err
 		}
-		return mgr.SyncAll()
+		if err := mgr.SetSourceEnabled(args[0], false); err != nil {
+			return err
+		}
+		fmt.Printf("disabled calendar %q\n", args[0])
+		return nil
 	},
 }
 
-var listCmd = &cobra.Command{
-	Use:   "list",
-	Short: "list configured calendars",
+var enableCmd = &cobra.Command{
+	Use:               "enable <name>",
+	Short:             "sync a disabled calendar again",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validCalendarNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		if err := mgr.SetSourceEnabled(args[0], true); err != nil {
+			return err
+		}
+		fmt.Printf("enabled calendar %q\n", args[0])
+		return nil
+	},
+}
+
+var mergeCmd = &cobra.Command{
+	Use:               "merge <dest> <src...>",
+	Short:             "merge calendars into dest and remove them",
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: validCalendarNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		if err := mgr.MergeSources(args[0], args[1:]); err != nil {
+			return err
+		}
+		fmt.Printf("merged %s into %q\n", strings.Join(args[1:], ", "), args[0])
+		return nil
+	},
+}
+
+var diffCmd = &cobra.Command{
+	Use:               "diff <name>",
+	Short:             "show what syncing a calendar would change, without syncing",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validCalendarNames,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		format, _ := cmd.Flags().GetString("output")
-		mgr, err := calendar.NewCalendarManager()
+		compact, _ := cmd.Flags().GetBool("compact")
+		mgr, err := newManager(cmd)
 		if err != nil {
 			return err
 		}
-		sources, err := mgr.LoadSources()
+		allowEmpty, _ := cmd.Flags().GetBool("allow-empty")
+		report, err := mgr.DiffSource(cmd.Context(), args[0], calendar.SyncOptions{AllowEmpty: allowEmpty})
 		if err != nil {
 			return err
 		}
-		if len(sources) == 0 {
-			fmt.Println("no calendars configured")
-			return nil
+		out, closeOut, err := outputWriter(cmd)
+		if err != nil {
+			return err
 		}
+		defer closeOut()
+
 		switch format {
 		case "json":
-			out, err := calendar.FormatSourcesJSON(sources)
+			data, err := calendar.FormatDiffJSON(report, compact)
+			if err == nil {
+				data, err = envelope(cmd, "diff", data, compact)
+			}
 			if err != nil {
 				return err
 			}
-			fmt.Println(out)
+			fmt.Fprintln(out, data)
 		default: // table
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "NAME\tURL")
-			for _, s := range sources {
-				fmt.Fprintf(w, "%s\t%s\n", s.Name, s.URL)
-			}
-			w.Flush()
+			fmt.Fprint(out, calendar.FormatDiff(report))
 		}
-		return nil
+		return closeOut()
 	},
 }
 
-var eventsCmd = &cobra.Command{
-	Use:   "events [today|week|month|YYYY-MM-DD [YYYY-MM-DD]]",
-	Short: "list upcoming events",
+var restoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "restore calendar sources from the most recent backup",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		format, _ := cmd.Flags().GetString("output")
-
-		mgr, err := calendar.NewCalendarManager()
+		mgr, err := newManager(cmd)
 		if err != nil {
 			return err
 		}
+		if err := mgr.RestoreSources(); err != nil {
+			return err
+		}
+		fmt.Println("restored calendar sources; run 'sync' to fetch their events")
+		return nil
+	},
+}
 
-		now := time.Now()
-		from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		to := from.AddDate(0, 0, 30)
-
-		if len(args) >= 1 {
-			switch args[0] {
-			case "today":
-				to = from.AddDate(0, 0, 1)
-			case "week":
-				to = from.AddDate(0, 0, 7)
-			case "month":
-				to = from.AddDate(0, 1, 0)
-			default:
-				t, err := time.Parse("2006-01-02", args[0])
+var syncCmd = &cobra.Command{
+	Use:               "sync [calendar...]",
+	Short:             "sync all calendars, or the named ones, from their iCal URLs",
+	ValidArgsFunction: validCalendarNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		// Per-source errors were already reported while syncing.
+		cmd.SilenceUsage = true
+		ifStale, _ := cmd.Flags().GetBool("if-stale")
+		allowEmpty, _ := cmd.Flags().GetBool("allow-empty")
+		opts := calendar.SyncOptions{IfStale: ifStale, AllowEmpty: allowEmpty}
+		onlyChanged, _ := cmd.Flags().GetBool("only-changed")
+		onlyChanged = onlyChanged && mgr.Log.Level < calendar.LogVerbose
+		if onlyChanged {
+			// Errors and warnings are still printed.
+			mgr.Log.Level = calendar.LogQuiet
+		}
+		if len(args) > 0 {
+			// Like SyncAll, every calendar is attempted.
+			var errs []error
+			for _, name := range args {
+				res, err := mgr.SyncSource(cmd.Context(), name, opts)
 				if err != nil {
-					return fmt.Errorf("invalid date %q (use YYYY-MM-DD, today, week, or month)", args[0])
+					errs = append(errs, fmt.Errorf("%s: %w", name, err))
+					if len(res.FeedErrors) == 0 {
+						continue
+					}
+					// Some feeds failed; the others were synced.
 				}
-				from = t
-				to = t.AddDate(0, 0, 1)
-				if len(args) >= 2 {
-					t2, err := time.Parse("2006-01-02", args[1])
-					if err != nil {
-						return fmt.Errorf("invalid end date %q (use YYYY-MM-DD)", args[1])
+				switch {
+				case res.Fresh:
+				case onlyChanged:
+					if res.Changed() {
+						fmt.Printf("%s: %d added, %d updated, %d removed\n", res.Calendar, res.Added, res.Updated, res.Removed)
 					}
-					to = t2.AddDate(0, 0, 1)
+				default:
+					mgr.Log.Infof("%s: %d added, %d updated, %d removed, %d unchanged\n",
+						res.Calendar, res.Added, res.Updated, res.Removed, res.Unchanged)
+				}
+			}
+			return errors.Join(errs...)
+		}
+		results, err := mgr.SyncAll(cmd.Context(), opts)
+		if onlyChanged {
+			for _, res := range results {
+				if res.Changed() {
+					fmt.Printf("%s: %d added, %d updated, %d removed\n", res.Calendar, res.Added, res.Updated, res.Removed)
 				}
 			}
 		}
+		return err
+	},
+}
+
+var refreshCmd = &cobra.Command{
+	Use:   "refresh [today|week|next-week|month|YYYY-MM-DD [YYYY-MM-DD]]",
+	Short: "sync all calendars, then list events",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+		from, to, err := parseRange(args, fromFlag, toFlag, mgr.Now())
+		if err != nil {
+			return err
+		}
+
+		// Per-source errors were already reported while syncing; show
+		// whatever did sync before failing.
+		cmd.SilenceUsage = true
+		ifStale, _ := cmd.Flags().GetBool("if-stale")
+		allowEmpty, _ := cmd.Flags().GetBool("allow-empty")
+		_, syncErr := mgr.SyncAll(cmd.Context(), calendar.SyncOptions{IfStale: ifStale, AllowEmpty: allowEmpty})
 
 		events, err := mgr.ListEvents(from, to)
 		if err != nil {
@@ -192,81 +446,1609 @@ var eventsCmd = &cobra.Command{
 		}
 		if len(events) == 0 {
 			fmt.Println("no events found")
+		} else {
+			printEventsTable(os.Stdout, events, mgr.Config.Layouts(), relativeNow(cmd, mgr), descriptionWidth(cmd))
+		}
+		return syncErr
+	},
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list configured calendars",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		compact, _ := cmd.Flags().GetBool("compact")
+		detailed, _ := cmd.Flags().GetBool("detailed")
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		sources, err := mgr.LoadSources()
+		if err != nil {
+			return err
+		}
+		if len(sources) == 0 {
+			fmt.Println("no calendars configured")
 			return nil
 		}
 
-		switch format {
-		case "json":
-			out, err := calendar.FormatEventsJSON(events)
+		out, closeOut, err := outputWriter(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeOut()
+
+		if detailed {
+			statuses, err := mgr.Status()
 			if err != nil {
 				return err
 			}
-			fmt.Println(out)
-		case "ics":
-			for _, e := range events {
-				raw, err := mgr.GetEventICS(e.UID)
+			if format == "json" {
+				data, err := calendar.FormatStatusJSON(statuses, compact)
+				if err == nil {
+					data, err = envelope(cmd, "sources", data, compact)
+				}
 				if err != nil {
-					continue
+					return err
 				}
-				fmt.Print(raw)
+				fmt.Fprintln(out, data)
+				return closeOut()
+			}
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tURL\tEVENTS\tLAST SYNC\tENABLED")
+			for _, st := range statuses {
+				url, lastSync := st.URL, "never synced"
+				switch {
+				case st.Local():
+					url, lastSync = "(local)", "local"
+				case st.Synced():
+					lastSync = st.LastSync.In(mgr.Config.Location()).Format("2006-01-02 15:04")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%t\n", st.Name, url, st.EventCount, lastSync, st.Enabled)
+			}
+			w.Flush()
+			return closeOut()
+		}
+
+		switch format {
+		case "json":
+			data, err := calendar.FormatSourcesJSON(sources, compact)
+			if err == nil {
+				data, err = envelope(cmd, "sources", data, compact)
 			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, data)
 		default: // table
-			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-			fmt.Fprintln(w, "TIME\tSUMMARY\tLOCATION\tCALENDAR")
-			for _, e := range events {
-				var timeStr string
-				if e.AllDay {
-					timeStr = e.Start.Format("2006-01-02") + " (all day)"
-				} else {
-					timeStr = e.Start.Format("2006-01-02 15:04")
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tURL")
+			for _, s := range sources {
+				url := s.URL
+				if s.Local() {
+					url = "(local)"
+				}
+				fmt.Fprintf(w, "%s\t%s\n", s.Name, url)
+				for _, u := range s.URLs {
+					fmt.Fprintf(w, "\t%s\n", u)
 				}
-				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", timeStr, e.Summary, e.Location, e.Calendar)
 			}
 			w.Flush()
 		}
-		return nil
+		return closeOut()
 	},
 }
 
-var getCmd = &cobra.Command{
-	Use:   "get <uid>",
-	Short: "get event details by uid",
-	Args:  cobra.ExactArgs(1),
+// parseRange resolves the [today|week|next-week|month|YYYY-MM-DD [YYYY-MM-DD]]
+// arguments shared by the event commands, or the --from/--to flag values
+// when either is set. With neither the range is the next 30 days.
+func parseRange(args []string, fromFlag, toFlag string, now time.Time) (from, to time.Time, err error) {
+	from = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	to = from.AddDate(0, 0, 30)
+
+	if fromFlag != "" || toFlag != "" {
+		if len(args) > 0 {
+			return from, to, fmt.Errorf("use either a positional range or --from/--to, not both")
+		}
+		if fromFlag != "" {
+			if from, _, err = parseRangeBound(fromFlag, now.Location()); err != nil {
+				return from, to, fmt.Errorf("invalid --from %q (use YYYY-MM-DD or RFC 3339)", fromFlag)
+			}
+			to = from.AddDate(0, 0, 30)
+		}
+		if toFlag != "" {
+			t, dateOnly, err := parseRangeBound(toFlag, now.Location())
+			if err != nil {
+				return from, to, fmt.Errorf("invalid --to %q (use YYYY-MM-DD or RFC 3339)", toFlag)
+			}
+			// A bare date includes the whole day, as with positional dates.
+			if dateOnly {
+				t = t.AddDate(0, 0, 1)
+			}
+			to = t
+		}
+		if to.Before(from) {
+			return from, to, fmt.Errorf("range end %s is before its start %s", to.Format(time.RFC3339), from.Format(time.RFC3339))
+		}
+		return from, to, nil
+	}
+
+	if len(args) >= 1 {
+		switch args[0] {
+		case "today":
+			to = from.AddDate(0, 0, 1)
+		case "week":
+			to = from.AddDate(0, 0, 7)
+		case "next-week":
+			// Monday through Sunday of the following week.
+			offset := (int(time.Monday) - int(from.Weekday()) + 7) % 7
+			if offset == 0 {
+				offset = 7
+			}
+			from = from.AddDate(0, 0, offset)
+			to = from.AddDate(0, 0, 7)
+		case "month":
+			to = from.AddDate(0, 1, 0)
+		default:
+			t, err := time.ParseInLocation("2006-01-02", args[0], now.Location())
+			if err != nil {
+				return from, to, fmt.Errorf("invalid date %q (use YYYY-MM-DD, today, week, next-week, or month)", args[0])
+			}
+			from = t
+			to = t.AddDate(0, 0, 1)
+			if len(args) >= 2 {
+				t2, err := time.ParseInLocation("2006-01-02", args[1], now.Location())
+				if err != nil {
+					return from, to, fmt.Errorf("invalid end date %q (use YYYY-MM-DD)", args[1])
+				}
+				to = t2.AddDate(0, 0, 1)
+			}
+		}
+	}
+	return from, to, nil
+}
+
+// parseRangeBound parses a --from/--to value, either YYYY-MM-DD (midnight
+// in loc) or an RFC 3339 timestamp, and reports whether it was a bare date.
+func parseRangeBound(s string, loc *time.Location) (time.Time, bool, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	return t, false, err
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show when each calendar was last synced",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		format, _ := cmd.Flags().GetString("output")
-
-		mgr, err := calendar.NewCalendarManager()
+		compact, _ := cmd.Flags().GetBool("compact")
+		mgr, err := newManager(cmd)
 		if err != nil {
 			return err
 		}
-
-		event, raw, err := mgr.GetEvent(args[0])
+		statuses, err := mgr.Status()
+		if err != nil {
+			return err
+		}
+		if len(statuses) == 0 {
+			fmt.Println("no calendars configured")
+			return nil
+		}
+		out, closeOut, err := outputWriter(cmd)
 		if err != nil {
 			return err
 		}
+		defer closeOut()
 
 		switch format {
 		case "json":
-			out, err := calendar.FormatEventJSON(event)
+			data, err := calendar.FormatStatusJSON(statuses, compact)
+			if err == nil {
+				data, err = envelope(cmd, "sources", data, compact)
+			}
 			if err != nil {
 				return err
 			}
-			fmt.Println(out)
-		case "ics":
-			fmt.Print(raw)
+			fmt.Fprintln(out, data)
 		default: // table
-			fmt.Print(calendar.FormatEvent(event))
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tEVENTS\tLAST SYNC")
+			for _, st := range statuses {
+				lastSync := "never synced"
+				if st.Local() {
+					lastSync = "local"
+				} else if st.Synced() {
+					lastSync = st.LastSync.In(mgr.Config.Location()).Format("2006-01-02 15:04")
+				}
+				fmt.Fprintf(w, "%s\t%d\t%s\n", st.Name, st.EventCount, lastSync)
+			}
+			w.Flush()
 		}
-		return nil
+		return closeOut()
 	},
 }
 
-func init() {
-	listCmd.Flags().StringP("output", "o", "table", "output format (table, json)")
-	eventsCmd.Flags().StringP("output", "o", "table", "output format (table, json, ics)")
-	getCmd.Flags().StringP("output", "o", "table", "output format (table, json, ics)")
+var eventsCmd = &cobra.Command{
+	Use:   "events [today|week|next-week|month|YYYY-MM-DD [YYYY-MM-DD]]",
+	Short: "list upcoming events",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if watch, _ := cmd.Flags().GetBool("watch"); watch {
+			return watchEvents(cmd, args)
+		}
+		return runEvents(cmd, args)
+	},
+}
+
+// runEvents lists events once; see eventsCmd.
+func runEvents(cmd *cobra.Command, args []string) error {
+	return runQuery(cmd, queryFromFlags(cmd, args))
+}
+
+// queryFromFlags collects the range and filter flags of events or query
+// save into a SavedQuery.
+func queryFromFlags(cmd *cobra.Command, args []string) calendar.SavedQuery {
+	q := calendar.SavedQuery{Range: args}
+	q.From, _ = cmd.Flags().GetString("from")
+	q.To, _ = cmd.Flags().GetString("to")
+	q.Calendars, _ = cmd.Flags().GetStringSlice("calendar")
+	q.Location, _ = cmd.Flags().GetString("location")
+	q.SummaryRegex, _ = cmd.Flags().GetString("summary-regex")
+	q.After, _ = cmd.Flags().GetString("after")
+	q.Before, _ = cmd.Flags().GetString("before")
+	q.ChangedSince, _ = cmd.Flags().GetString("changed-since")
+	q.RSVP, _ = cmd.Flags().GetString("rsvp")
+	q.MyEmail, _ = cmd.Flags().GetString("my-email")
+	q.FutureOnly, _ = cmd.Flags().GetBool("future-only")
+	q.LimitPerCalendar, _ = cmd.Flags().GetInt("limit-per-calendar")
+	q.Sort, _ = cmd.Flags().GetString("sort")
+	q.Near, _ = cmd.Flags().GetString("near")
+	return q
+}
+
+// runQuery lists the events matching q in the format given by cmd's output
+// flags.
+func runQuery(cmd *cobra.Command, q calendar.SavedQuery) error {
+	format, _ := cmd.Flags().GetString("output")
+	compact, _ := cmd.Flags().GetBool("compact")
+	fieldSpec, _ := cmd.Flags().GetString("fields")
+	fields, err := calendar.ParseFields(fieldSpec)
+	if err != nil {
+		return err
+	}
+	if descriptionWidth(cmd) < 0 {
+		return fmt.Errorf("--desc must be positive")
+	}
+
+	mgr, err := newManager(cmd)
+	if err != nil {
+		return err
+	}
+
+	from, to, err := parseRange(q.Range, q.From, q.To, mgr.Now())
+	if err != nil {
+		return err
+	}
+
+	events, err := mgr.ListEvents(from, to)
+	if err != nil {
+		return err
+	}
+	if events, err = q.Filter(events, mgr.Config, mgr.Now()); err != nil {
+		return err
+	}
+	if count, _ := cmd.Flags().GetBool("count"); count {
+		fmt.Println(len(events))
+		return nil
+	}
+	if len(events) == 0 {
+		fmt.Println("no events found")
+		return nil
+	}
+
+	out, closeOut, err := outputWriter(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeOut()
+
+	switch format {
+	case "json":
+		var data string
+		if fieldSpec != "" {
+			data, err = calendar.FormatEventFieldsJSON(events, fields, compact)
+		} else {
+			data, err = calendar.FormatEventsJSON(events, compact)
+		}
+		if err == nil {
+			data, err = envelope(cmd, "events", data, compact)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, data)
+	case "jsonl":
+		if fieldSpec != "" {
+			enc := json.NewEncoder(out)
+			for _, p := range calendar.ProjectEvents(events, fields) {
+				if err := enc.Encode(p); err != nil {
+					return err
+				}
+			}
+		} else if err := calendar.FormatEventsJSONL(out, events); err != nil {
+			return err
+		}
+	case "csv":
+		if err := calendar.FormatEventsCSV(out, events, fields); err != nil {
+			return err
+		}
+	case "ics":
+		includeCancelled, _ := cmd.Flags().GetBool("include-cancelled")
+		data, err := mgr.ExportICS(events, calendar.ExportOptions{IncludeCancelled: includeCancelled})
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(out, data)
+	default: // table
+		printEventsTable(out, events, mgr.Config.Layouts(), relativeNow(cmd, mgr), descriptionWidth(cmd))
+	}
+	return closeOut()
+}
+
+// printEventsTable writes events as an aligned table to out, showing times
+// with the configured layouts. A non-zero now adds a STARTS IN column
+// relative to it, and a positive desc a DESCRIPTION column cut to that many
+// runes.
+func printEventsTable(out io.Writer, events []calendar.Event, l calendar.Layouts, now time.Time, desc int) {
+	l = l.Or(calendar.Layouts{Date: "2006-01-02", Time: "15:04"})
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	header := "TIME\tSUMMARY\tLOCATION\tCALENDAR"
+	if !now.IsZero() {
+		header = "TIME\tSTARTS IN\tSUMMARY\tLOCATION\tCALENDAR"
+	}
+	if desc > 0 {
+		header += "\tDESCRIPTION"
+	}
+	fmt.Fprintln(w, header)
+	for _, e := range events {
+		var timeStr string
+		if e.AllDay {
+			timeStr = e.Start.Format(l.Date) + " (all day)"
+		} else {
+			timeStr = e.Start.Format(l.Date + " " + l.Time)
+		}
+		if !now.IsZero() {
+			timeStr += "\t" + humanizeRelative(e.Start, now)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s", timeStr, calendar.DisplaySummary(&e), e.Location, e.Calendar)
+		if desc > 0 {
+			fmt.Fprintf(w, "\t%s", previewText(e.Description, desc))
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+}
+
+// descriptionWidth returns the --desc width, or 0 when the flag isn't set
+// or the command doesn't have it.
+func descriptionWidth(cmd *cobra.Command) int {
+	n, _ := cmd.Flags().GetInt("desc")
+	return n
+}
+
+// previewText joins s onto one line and cuts it to at most n runes, ending
+// with an ellipsis when anything was dropped.
+func previewText(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// relativeNow returns the time --relative columns are measured from, or
+// zero when --relative isn't set.
+func relativeNow(cmd *cobra.Command, mgr *calendar.CalendarManager) time.Time {
+	if relative, _ := cmd.Flags().GetBool("relative"); relative {
+		return mgr.Now()
+	}
+	return time.Time{}
+}
+
+// humanizeRelative describes t relative to now as "now", "in 45m", "in 2h",
+// "in 3 days" or, for past times, "3h ago". Units are truncated, so 2h59m
+// away reads "in 2h".
+func humanizeRelative(t, now time.Time) string {
+	d := t.Sub(now)
+	past := d < 0
+	if past {
+		d = -d
+	}
+	var s string
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		s = fmt.Sprintf("%dm", int(d.Minutes()))
+	case d < 24*time.Hour:
+		s = fmt.Sprintf("%dh", int(d.Hours()))
+	case d < 48*time.Hour:
+		s = "1 day"
+	default:
+		s = fmt.Sprintf("%d days", int(d.Hours()/24))
+	}
+	if past {
+		return s + " ago"
+	}
+	return "in " + s
+}
+
+// parseNextArg interprets the argument to next as either an event count
+// ("10") or a window ("3h", "90m", "2d").
+func parseNextArg(arg string) (count int, window time.Duration, err error) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n <= 0 {
+			return 0, 0, fmt.Errorf("count must be positive, got %d", n)
+		}
+		return n, 0, nil
+	}
+	if days, ok := strings.CutSuffix(arg, "d"); ok {
+		if n, err := strconv.Atoi(days); err == nil && n > 0 {
+			return 0, time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	if d, err := time.ParseDuration(arg); err == nil && d > 0 {
+		return 0, d, nil
+	}
+	return 0, 0, fmt.Errorf("invalid argument %q (use a count like 10 or a window like 3h, 90m, 2d)", arg)
+}
+
+var nextCmd = &cobra.Command{
+	Use:   "next [count|duration]",
+	Short: "show the next events, by count (next 10) or window (next 3h)",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		compact, _ := cmd.Flags().GetBool("compact")
+
+		count, window := 1, time.Duration(0)
+		if len(args) == 1 {
+			var err error
+			count, window, err = parseNextArg(args[0])
+			if err != nil {
+				return err
+			}
+		}
+
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		now := mgr.Now()
+		var events []calendar.Event
+		if window > 0 {
+			events, err = mgr.ListEvents(now, now.Add(window))
+		} else {
+			events, err = mgr.NextEvents(now, count)
+		}
+		if err != nil {
+			return err
+		}
+		if len(events) == 0 {
+			fmt.Println("no upcoming events")
+			return nil
+		}
+
+		out, closeOut, err := outputWriter(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeOut()
+
+		switch format {
+		case "json":
+			data, err := calendar.FormatEventsJSON(events, compact)
+			if err == nil {
+				data, err = envelope(cmd, "events", data, compact)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, data)
+		default: // table
+			printEventsTable(out, events, mgr.Config.Layouts(), relativeNow(cmd, mgr), descriptionWidth(cmd))
+		}
+		return closeOut()
+	},
+}
+
+var freeCmd = &cobra.Command{
+	Use:   "free <duration> [today|week|next-week|month|YYYY-MM-DD [YYYY-MM-DD]]",
+	Short: "find free slots of at least duration within working hours",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		compact, _ := cmd.Flags().GetBool("compact")
+
+		minLen, err := time.ParseDuration(args[0])
+		if err != nil || minLen <= 0 {
+			return fmt.Errorf("invalid duration %q (use e.g. 30m or 1h)", args[0])
+		}
+
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		now := mgr.Now()
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+		from, to, err := parseRange(args[1:], fromFlag, toFlag, now)
+		if err != nil {
+			return err
+		}
+		if from.Before(now) {
+			from = now.Truncate(time.Minute)
+		}
+		buffer, _ := cmd.Flags().GetDuration("buffer")
+		if buffer < 0 {
+			return fmt.Errorf("--buffer must not be negative")
+		}
+		slots, err := mgr.FreeSlots(from, to, minLen, buffer)
+		if err != nil {
+			return err
+		}
+		if len(slots) == 0 {
+			fmt.Println("no free slots found")
+			return nil
+		}
+
+		out, closeOut, err := outputWriter(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeOut()
+
+		switch format {
+		case "json":
+			data, err := calendar.FormatSlotsJSON(slots, compact)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, data)
+		default: // table
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "START\tEND\tLENGTH")
+			for _, sl := range slots {
+				fmt.Fprintf(w, "%s\t%s\t%s\n",
+					sl.Start.Format("Mon 2006-01-02 15:04"), sl.End.Format("15:04"), formatDuration(sl.End.Sub(sl.Start)))
+			}
+			w.Flush()
+		}
+		return closeOut()
+	},
+}
+
+var busyCmd = &cobra.Command{
+	Use:   "busy [today|week|next-week|month|YYYY-MM-DD [YYYY-MM-DD]]",
+	Short: "show busy time without event details, e.g. as a VFREEBUSY to share",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		compact, _ := cmd.Flags().GetBool("compact")
+
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+		from, to, err := parseRange(args, fromFlag, toFlag, mgr.Now())
+		if err != nil {
+			return err
+		}
+		switch format {
+		case "ics", "json", "table":
+		default:
+			return fmt.Errorf("invalid output format %q (use table, json or ics)", format)
+		}
+
+		if format == "ics" {
+			data, err := mgr.ExportFreeBusy(from, to)
+			if err != nil {
+				return err
+			}
+			out, closeOut, err := outputWriter(cmd)
+			if err != nil {
+				return err
+			}
+			defer closeOut()
+			fmt.Fprint(out, data)
+			return closeOut()
+		}
+
+		periods, err := mgr.BusyPeriods(from, to)
+		if err != nil {
+			return err
+		}
+		if len(periods) == 0 && format == "table" {
+			fmt.Println("no busy time found")
+			return nil
+		}
+		out, closeOut, err := outputWriter(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeOut()
+
+		if format == "json" {
+			data, err := calendar.FormatSlotsJSON(periods, compact)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, data)
+			return closeOut()
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "START\tEND\tLENGTH")
+		for _, p := range periods {
+			fmt.Fprintf(w, "%s\t%s\t%s\n",
+				p.Start.Format("Mon 2006-01-02 15:04"), p.End.Format("15:04"), formatDuration(p.End.Sub(p.Start)))
+		}
+		w.Flush()
+		return closeOut()
+	},
+}
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "show what recent syncs added, updated and removed in each calendar",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		compact, _ := cmd.Flags().GetBool("compact")
+		last, _ := cmd.Flags().GetInt("last")
+		if last < 0 {
+			return fmt.Errorf("--last must not be negative")
+		}
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		records, err := mgr.SyncHistory(last)
+		if err != nil {
+			return err
+		}
+		switch format {
+		case "json", "table":
+		default:
+			return fmt.Errorf("invalid output format %q (use table or json)", format)
+		}
+		if len(records) == 0 && format == "table" {
+			fmt.Println("no syncs recorded")
+			return nil
+		}
+
+		out, closeOut, err := outputWriter(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeOut()
+
+		if format == "json" {
+			data, err := calendar.FormatSyncHistoryJSON(records, compact)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, data)
+		} else {
+			w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "TIME\tCALENDAR\tADDED\tUPDATED\tREMOVED\tUNCHANGED\tERROR")
+			for _, r := range records {
+				t := r.Time.In(mgr.Config.Location()).Format("2006-01-02 15:04:05")
+				for _, src := range r.Sources {
+					fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%d\t%s\n",
+						t, src.Calendar, src.Added, src.Updated, src.Removed, src.Unchanged, src.Error)
+				}
+			}
+			w.Flush()
+		}
+		return closeOut()
+	},
+}
+
+var conflictsCmd = &cobra.Command{
+	Use:   "conflicts [today|week|next-week|month|YYYY-MM-DD [YYYY-MM-DD]]",
+	Short: "show overlapping events and the calendars they come from",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		compact, _ := cmd.Flags().GetBool("compact")
+		crossOnly, _ := cmd.Flags().GetBool("cross-only")
+		switch format {
+		case "json", "table":
+		default:
+			return fmt.Errorf("invalid output format %q (use table or json)", format)
+		}
+
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		fromFlag, _ := cmd.Flags().GetString("from")
+		toFlag, _ := cmd.Flags().GetString("to")
+		from, to, err := parseRange(args, fromFlag, toFlag, mgr.Now())
+		if err != nil {
+			return err
+		}
+
+		conflicts, err := mgr.Conflicts(from, to)
+		if err != nil {
+			return err
+		}
+		if crossOnly {
+			conflicts = calendar.CrossCalendarConflicts(conflicts)
+		}
+		if len(conflicts) == 0 && format == "table" {
+			fmt.Println("no conflicts found")
+			return nil
+		}
+
+		out, closeOut, err := outputWriter(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeOut()
+
+		if format == "json" {
+			data, err := calendar.FormatConflictsJSON(conflicts, compact)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, data)
+			return closeOut()
+		}
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "OVERLAP\tFIRST\tSECOND\tCALENDARS")
+		for _, c := range conflicts {
+			calendars := c.FirstCalendar + " / " + c.SecondCalendar
+			if c.SameCalendar {
+				calendars = c.FirstCalendar + " (same calendar)"
+			}
+			fmt.Fprintf(w, "%s-%s\t%s\t%s\t%s\n",
+				c.Start.Format("Mon 2006-01-02 15:04"), c.End.Format("15:04"),
+				calendar.DisplaySummary(&c.First), calendar.DisplaySummary(&c.Second), calendars)
+		}
+		w.Flush()
+		return closeOut()
+	},
+}
+
+var heatmapCmd = &cobra.Command{
+	Use:   "heatmap [YYYY]",
+	Short: "show a year of events as a per-day grid",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		loc := mgr.Config.Location()
+		year := mgr.Now().Year()
+		if len(args) == 1 {
+			year, err = strconv.Atoi(args[0])
+			if err != nil || year < 1 || year > 9999 {
+				return fmt.Errorf("invalid year %q (use YYYY)", args[0])
+			}
+		}
+		from := time.Date(year, time.January, 1, 0, 0, 0, 0, loc)
+		events, err := mgr.ListEvents(from, from.AddDate(1, 0, 0))
+		if err != nil {
+			return err
+		}
+		fmt.Print(calendar.FormatHeatmap(calendar.DailyCounts(events, loc), year))
+		return nil
+	},
+}
+
+// formatDuration renders d as hours and minutes, e.g. "1h30m" or "45m".
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dh%dm", h, m)
+}
+
+var openCmd = &cobra.Command{
+	Use:   "open <uid>",
+	Short: "open an event's meeting URL in the browser",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		event, _, err := mgr.GetEvent(args[0])
+		if err != nil {
+			return err
+		}
+		url := calendar.MeetingURL(event)
+		if url == "" {
+			return fmt.Errorf("event %q has no URL", event.UID)
+		}
+		if err := openURL(url); err != nil {
+			return fmt.Errorf("opening %s: %w", url, err)
+		}
+		fmt.Printf("opening %s\n", url)
+		return nil
+	},
+}
+
+var editCmd = &cobra.Command{
+	Use:   "edit <uid>",
+	Short: "edit a stored event",
+	Long: `edit a stored event
+
+Events in synced calendars are overwritten by the next sync, so editing them
+requires --force.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		event, _, err := mgr.GetEvent(args[0])
+		if err != nil {
+			return err
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		readOnly, err := mgr.IsReadOnly(event.Calendar)
+		if err != nil {
+			return err
+		}
+		if readOnly && !force {
+			return fmt.Errorf("event %q comes from synced calendar %q and the next sync would overwrite it (use --force to edit anyway)", event.UID, event.Calendar)
+		}
+
+		layout := timeInputLayout(event.AllDay)
+		start := event.Start.Format(layout)
+		var end string
+		if !event.End.IsZero() {
+			end = event.End.Format(layout)
+		}
+		validate := func(optional bool) func(string) error {
+			return func(s string) error {
+				if s == "" && optional {
+					return nil
+				}
+				_, err := time.ParseInLocation(layout, s, event.Start.Location())
+				return err
+			}
+		}
+		form := huh.NewForm(
+			huh.NewGroup(
+				huh.NewInput().Title("Summary").Value(&event.Summary),
+				huh.NewInput().Title("Start").Description(layout).Value(&start).Validate(validate(false)),
+				huh.NewInput().Title("End").Description(layout).Value(&end).Validate(validate(true)),
+				huh.NewInput().Title("Location").Value(&event.Location),
+				huh.NewText().Title("Description").Value(&event.Description),
+			),
+		)
+		if err := form.Run(); err != nil {
+			return err
+		}
+
+		loc := event.Start.Location()
+		if event.Start, err = time.ParseInLocation(layout, start, loc); err != nil {
+			return err
+		}
+		event.End = time.Time{}
+		if end != "" {
+			if event.End, err = time.ParseInLocation(layout, end, loc); err != nil {
+				return err
+			}
+		}
+		if err := mgr.UpdateEvent(event.UID, *event); err != nil {
+			return err
+		}
+		fmt.Printf("updated event %q\n", event.UID)
+		return nil
+	},
+}
+
+var createCmd = &cobra.Command{
+	Use:   "create <calendar>",
+	Short: "create an event in a local calendar",
+	Long: `create an event in a local calendar
+
+The calendar is created if it doesn't exist. Without --summary and --start
+the event's details are asked for interactively.`,
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validCalendarNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var e calendar.Event
+		e.Summary, _ = cmd.Flags().GetString("summary")
+		e.Location, _ = cmd.Flags().GetString("location")
+		e.Description, _ = cmd.Flags().GetString("description")
+		e.AllDay, _ = cmd.Flags().GetBool("all-day")
+		start, _ := cmd.Flags().GetString("start")
+		end, _ := cmd.Flags().GetString("end")
+
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		loc := mgr.Config.Location()
+		layout := timeInputLayout(e.AllDay)
+
+		if e.Summary == "" || start == "" {
+			validate := func(optional bool) func(string) error {
+				return func(s string) error {
+					if s == "" && optional {
+						return nil
+					}
+					_, err := time.ParseInLocation(layout, s, loc)
+					return err
+				}
+			}
+			form := huh.NewForm(
+				huh.NewGroup(
+					huh.NewInput().Title("Summary").Value(&e.Summary),
+					huh.NewInput().Title("Start").Description(layout).Value(&start).Validate(validate(false)),
+					huh.NewInput().Title("End").Description(layout).Value(&end).Validate(validate(true)),
+					huh.NewInput().Title("Location").Value(&e.Location),
+					huh.NewText().Title("Description").Value(&e.Description),
+				),
+			)
+			if err := form.Run(); err != nil {
+				return err
+			}
+		}
+
+		if e.Start, err = time.ParseInLocation(layout, start, loc); err != nil {
+			return fmt.Errorf("invalid --start %q (use %s)", start, layout)
+		}
+		if end != "" {
+			if e.End, err = time.ParseInLocation(layout, end, loc); err != nil {
+				return fmt.Errorf("invalid --end %q (use %s)", end, layout)
+			}
+		}
+		uid, err := mgr.CreateEvent(args[0], e)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("created event %s\n", uid)
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:               "import <calendar> [file.ics]",
+	Short:             "import an .ics file, such as an emailed invite, into a local calendar",
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: validCalendarNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var in io.Reader = os.Stdin
+		if len(args) == 2 && args[1] != "-" {
+			f, err := os.Open(args[1])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		results, err := mgr.ImportICS(args[0], in)
+		for _, r := range results {
+			if r.RecurrenceID != "" {
+				fmt.Printf("%s %s (instance %s)\n", r.Action, r.UID, r.RecurrenceID)
+			} else {
+				fmt.Printf("%s %s\n", r.Action, r.UID)
+			}
+		}
+		return err
+	},
+}
+
+var importDirCmd = &cobra.Command{
+	Use:               "import-dir <calendar> <dir>",
+	Short:             "import every .ics or .ics.gz file in a directory, such as an old export, into a local calendar",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: validCalendarNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		recursive, _ := cmd.Flags().GetBool("recursive")
+		workers, _ := cmd.Flags().GetInt("workers")
+		opts := calendar.ImportDirOptions{Recursive: recursive, Workers: workers}
+		if mgr.Log.Level >= calendar.LogNormal {
+			opts.Progress = func(done, total int) {
+				fmt.Fprintf(os.Stderr, "\rimported %d/%d files", done, total)
+				if done == total {
+					fmt.Fprintln(os.Stderr)
+				}
+			}
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		results, err := mgr.ImportDir(ctx, args[0], args[1], opts)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+
+		failed := 0
+		for _, r := range results {
+			if r.Err != nil {
+				failed++
+				fmt.Printf("failed %s: %v\n", r.Path, r.Err)
+				continue
+			}
+			counts := make(map[string]int)
+			for _, res := range r.Results {
+				counts[res.Action]++
+			}
+			var parts []string
+			for _, action := range []string{calendar.ImportAdded, calendar.ImportUpdated, calendar.ImportUnchanged, calendar.ImportCancelled, calendar.ImportNotFound} {
+				if counts[action] > 0 {
+					parts = append(parts, fmt.Sprintf("%d %s", counts[action], action))
+				}
+			}
+			if len(r.Duplicates) > 0 {
+				parts = append(parts, fmt.Sprintf("%d also in an earlier file", len(r.Duplicates)))
+			}
+			mgr.Log.Infof("ok %s (%s)\n", r.Path, strings.Join(parts, ", "))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d files failed to import", failed, len(results))
+		}
+		return nil
+	},
+}
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete <uid>",
+	Short: "delete a stored event",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		event, _, err := mgr.GetEvent(args[0])
+		if err != nil {
+			return err
+		}
+		readOnly, err := mgr.IsReadOnly(event.Calendar)
+		if err != nil {
+			return err
+		}
+		if readOnly {
+			fmt.Fprintf(os.Stderr, "warning: %q comes from synced calendar %q and the next sync will recreate it\n", event.UID, event.Calendar)
+		}
+
+		yes, _ := cmd.Flags().GetBool("yes")
+		if !yes {
+			confirm := huh.NewConfirm().
+				Title(fmt.Sprintf("Delete %q?", calendar.DisplaySummary(event))).
+				Description(event.Start.Format("Mon, 02 Jan 2006 15:04") + " in " + event.Calendar).
+				Value(&yes)
+			if err := confirm.Run(); err != nil {
+				return err
+			}
+			if !yes {
+				fmt.Println("cancelled")
+				return nil
+			}
+		}
+
+		if err := mgr.DeleteEvent(event.UID); err != nil {
+			return err
+		}
+		fmt.Printf("deleted event %q\n", event.UID)
+		return nil
+	},
+}
+
+// timeInputLayout is the layout used to type event times: a plain date for
+// all-day events, otherwise a date and time.
+func timeInputLayout(allDay bool) string {
+	if allDay {
+		return "2006-01-02"
+	}
+	return "2006-01-02 15:04"
+}
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "save event filters under a name and run them again",
+}
+
+var querySaveCmd = &cobra.Command{
+	Use:   "save <name> [today|week|next-week|month|YYYY-MM-DD [YYYY-MM-DD]]",
+	Short: "save the range and filters given as for events under a name",
+	Args:  cobra.RangeArgs(1, 3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		q := queryFromFlags(cmd, args[1:])
+		// Check the range now too, so a typo doesn't wait for the first run.
+		if _, _, err := parseRange(q.Range, q.From, q.To, mgr.Now()); err != nil {
+			return err
+		}
+		if err := mgr.SaveQuery(args[0], q); err != nil {
+			return err
+		}
+		fmt.Printf("saved query %q: events %s\n", args[0], q)
+		return nil
+	},
+}
+
+var queryRunCmd = &cobra.Command{
+	Use:               "run <name>",
+	Short:             "list the events matching a saved query",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validQueryNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		queries, err := mgr.SavedQueries()
+		if err != nil {
+			return err
+		}
+		q, ok := queries[args[0]]
+		if !ok {
+			return fmt.Errorf("query %q not found", args[0])
+		}
+		return runQuery(cmd, q)
+	},
+}
+
+var queryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list saved queries",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		queries, err := mgr.SavedQueries()
+		if err != nil {
+			return err
+		}
+		names, err := mgr.SavedQueryNames()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("no saved queries")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tQUERY")
+		for _, name := range names {
+			fmt.Fprintf(w, "%s\tevents %s\n", name, queries[name])
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var queryDeleteCmd = &cobra.Command{
+	Use:               "delete <name>",
+	Short:             "delete a saved query",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validQueryNames,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+		if err := mgr.DeleteQuery(args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("deleted query %q\n", args[0])
+		return nil
+	},
+}
+
+// validQueryNames completes saved query names.
+func validQueryNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	mgr, err := newManager(cmd)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names, _ := mgr.SavedQueryNames()
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+var tzCmd = &cobra.Command{
+	Use:   "tz",
+	Short: "look up IANA time zone names",
+}
+
+var tzListCmd = &cobra.Command{
+	Use:   "list [filter]",
+	Short: "list time zone names, optionally only those containing filter",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		zones, err := calendar.TimeZones(filter)
+		if err != nil {
+			return err
+		}
+		if len(zones) == 0 {
+			return fmt.Errorf("no time zones match %q", filter)
+		}
+		for _, z := range zones {
+			fmt.Println(z)
+		}
+		return nil
+	},
+}
+
+var tzNowCmd = &cobra.Command{
+	Use:               "now <zone>",
+	Short:             "show the current time in a time zone",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: validTimeZones,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		loc, err := calendar.LoadTimeZone(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Println(time.Now().In(loc).Format("2006-01-02 15:04:05 MST (-07:00)"))
+		return nil
+	},
+}
+
+// validTimeZones completes IANA time zone names.
+func validTimeZones(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	zones, _ := calendar.TimeZones("")
+	var names []string
+	for _, z := range zones {
+		if strings.HasPrefix(z, toComplete) {
+			names = append(names, z)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "manage profiles, each with its own calendars",
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list profiles, marking the active one",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		base, err := baseDir(cmd)
+		if err != nil {
+			return err
+		}
+		active, err := activeProfile(cmd, base)
+		if err != nil {
+			return err
+		}
+		profiles, err := calendar.ListProfiles(base)
+		if err != nil {
+			return err
+		}
+		for _, p := range profiles {
+			marker := " "
+			if p == active {
+				marker = "*"
+			}
+			fmt.Printf("%s %s\n", marker, p)
+		}
+		return nil
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "make a profile the active one",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		base, err := baseDir(cmd)
+		if err != nil {
+			return err
+		}
+		if err := calendar.UseProfile(base, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Using profile %s\n", args[0])
+		if env := os.Getenv("CALENDAR_PROFILE"); env != "" && env != args[0] {
+			fmt.Fprintf(os.Stderr, "warning: CALENDAR_PROFILE=%s overrides this until it is unset\n", env)
+		}
+		return nil
+	},
+}
+
+var profileCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "create an empty profile",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		base, err := baseDir(cmd)
+		if err != nil {
+			return err
+		}
+		if err := calendar.CreateProfile(base, args[0]); err != nil {
+			return err
+		}
+		fmt.Printf("Created profile %s\n", args[0])
+		return nil
+	},
+}
+
+// buildInfo describes the running binary for the version command.
+type buildInfo struct {
+	Version   string            `json:"version"`
+	GoVersion string            `json:"go_version"`
+	Module    string            `json:"module,omitempty"`
+	Settings  map[string]string `json:"settings,omitempty"`
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "print version and build information",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		compact, _ := cmd.Flags().GetBool("compact")
+
+		info := buildInfo{Version: calendar.Version, GoVersion: runtime.Version()}
+		if bi, ok := debug.ReadBuildInfo(); ok {
+			info.Module = bi.Main.Path
+			// Binaries installed with go install carry their module version.
+			if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+				info.Version = bi.Main.Version
+			}
+			info.Settings = make(map[string]string, len(bi.Settings))
+			for _, s := range bi.Settings {
+				info.Settings[s.Key] = s.Value
+			}
+		}
+
+		out, closeOut, err := outputWriter(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeOut()
+
+		switch format {
+		case "json":
+			var data []byte
+			if compact {
+				data, err = json.Marshal(info)
+			} else {
+				data, err = json.MarshalIndent(info, "", "  ")
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, string(data))
+		default: // text
+			fmt.Fprintf(out, "calendar %s\n", info.Version)
+			fmt.Fprintf(out, "%-13s %s\n", "go:", info.GoVersion)
+			for _, key := range []string{"vcs.revision", "vcs.time", "vcs.modified", "GOOS", "GOARCH"} {
+				if v, ok := info.Settings[key]; ok {
+					fmt.Fprintf(out, "%-13s %s\n", key+":", v)
+				}
+			}
+		}
+		return closeOut()
+	},
+}
+
+var getCmd = &cobra.Command{
+	Use:               "get <uid|summary>",
+	Short:             "get event details by uid or a unique summary substring",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: uidCompleter,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("output")
+		compact, _ := cmd.Flags().GetBool("compact")
+
+		mgr, err := newManager(cmd)
+		if err != nil {
+			return err
+		}
+
+		event, _, err := mgr.ResolveEvent(args[0])
+		var ambiguous *calendar.AmbiguousEventError
+		if errors.As(err, &ambiguous) {
+			cmd.SilenceUsage = true
+			w := tabwriter.NewWriter(os.Stderr, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "UID\tSUMMARY\tCALENDAR")
+			for _, e := range ambiguous.Candidates {
+				fmt.Fprintf(w, "%s\t%s\t%s\n", e.UID, calendar.DisplaySummary(&e), e.Calendar)
+			}
+			w.Flush()
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		copyMode, _ := cmd.Flags().GetString("copy")
+		switch copyMode {
+		case "":
+		case "event":
+			if err := copyToClipboard(calendar.FormatEventLayouts(event, mgr.Config.Layouts())); err != nil {
+				return err
+			}
+			fmt.Println("copied event to clipboard")
+			return nil
+		case "url":
+			if event.URL == "" {
+				return fmt.Errorf("event %q has no URL", event.UID)
+			}
+			if err := copyToClipboard(event.URL); err != nil {
+				return err
+			}
+			fmt.Println("copied URL to clipboard")
+			return nil
+		default:
+			return fmt.Errorf("invalid --copy value %q (use event or url)", copyMode)
+		}
+
+		out, closeOut, err := outputWriter(cmd)
+		if err != nil {
+			return err
+		}
+		defer closeOut()
+
+		switch format {
+		case "json":
+			data, err := calendar.FormatEventJSON(event, compact)
+			if err == nil {
+				data, err = envelope(cmd, "event", data, compact)
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(out, data)
+		case "ics":
+			data, err := mgr.EventICS(*event)
+			if err != nil {
+				return err
+			}
+			if pretty, _ := cmd.Flags().GetBool("pretty"); pretty {
+				data = calendar.CanonicalizeICS(data)
+			}
+			fmt.Fprint(out, data)
+		default: // table
+			fmt.Fprint(out, calendar.FormatEventLayouts(event, mgr.Config.Layouts()))
+		}
+		return closeOut()
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().String("dir", "", "base directory holding profiles (default $CALENDAR_DIR or ~/.config/calendar)")
+	rootCmd.PersistentFlags().String("profile", "", "profile to use (default $CALENDAR_PROFILE or the one set with profile use)")
+	rootCmd.PersistentFlags().Bool("insecure", false, "skip TLS certificate verification, for testing only (add remembers it for the new source)")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "only print errors")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "print per-event and HTTP timing detail")
+
+	addCmd.Flags().Bool("force", false, "add the source even if another one already uses its URL")
+	addCmd.Flags().String("type", "ical", "source type (ical, caldav)")
+	addCmd.Flags().String("username", "", "username for CalDAV basic auth")
+	addCmd.Flags().String("password-env", "", "environment variable holding the CalDAV password")
+	addCmd.Flags().String("color", "", "display color for the calendar's events in JSON output (e.g. #4285f4)")
+	addCmd.Flags().Bool("case-sensitive", false, "allow names that differ from an existing calendar only by case")
+	addCmd.Flags().Bool("no-verify", false, "don't check that the URL serves a calendar")
+	addCmd.Flags().String("refresh", "", "minimum time between syncs with --if-stale (e.g. 6h, 24h)")
+	syncCmd.Flags().Bool("only-changed", false, "print only calendars whose events changed, and nothing otherwise (unless --verbose)")
+	for _, c := range []*cobra.Command{syncCmd, refreshCmd} {
+		c.Flags().Bool("if-stale", false, "skip calendars synced within their refresh interval")
+		c.Flags().Bool("allow-empty", false, "let a feed with no events clear a calendar's stored events")
+	}
+	listCmd.Flags().StringP("output", "o", "table", "output format (table, json)")
+	diffCmd.Flags().StringP("output", "o", "table", "output format (table, json)")
+	diffCmd.Flags().Bool("allow-empty", false, "compare as sync --allow-empty would when a feed has no events")
+	eventsCmd.Flags().Bool("watch", false, "redraw the table every --interval until interrupted")
+	eventsCmd.Flags().Duration("interval", time.Minute, "with --watch, how often to redraw")
+	eventsCmd.Flags().Bool("sync", false, "with --watch, sync before each redraw")
+	for _, c := range []*cobra.Command{eventsCmd, querySaveCmd} {
+		c.Flags().StringSlice("calendar", nil, "only show events from these calendars (repeatable)")
+		c.Flags().StringP("location", "l", "", "only show events whose location contains this text")
+		c.Flags().String("summary-regex", "", "only show events whose summary matches this Go regexp (use ^ and $ to anchor)")
+		c.Flags().String("after", "", "only show timed events starting at or after this time of day (HH:MM)")
+		c.Flags().String("before", "", "only show timed events starting before this time of day (HH:MM)")
+		c.Flags().String("sort", "start", "sort order (start, priority)")
+		c.Flags().String("near", "", "sort events by distance from lat,lon, those without GEO last")
+		c.Flags().Bool("future-only", false, "drop recurrence instances that start before now, even inside the range")
+		c.Flags().Int("limit-per-calendar", 0, "show at most this many events from each calendar (0 for no limit)")
+		c.Flags().String("changed-since", "", "only show events modified on or after this date (YYYY-MM-DD)")
+		c.Flags().String("rsvp", "", "only show events you responded to this way (accepted, tentative, declined, needs-action)")
+		c.Flags().String("my-email", "", "your attendee address for --rsvp (default email from config.json)")
+	}
+	for _, c := range []*cobra.Command{eventsCmd, queryRunCmd} {
+		c.Flags().StringP("output", "o", "table", "output format (table, json, jsonl, csv, ics)")
+		c.Flags().String("fields", "", "comma-separated fields for json/jsonl/csv output, named as in json output and in any case (e.g. uid,summary,start)")
+		c.Flags().Bool("include-cancelled", false, "keep cancelled events in ics output")
+		c.Flags().Bool("count", false, "print only the number of matching events")
+		c.Flags().Int("desc", 0, "add a DESCRIPTION column cut to this many characters (table output only; default 40)")
+		c.Flags().Lookup("desc").NoOptDefVal = "40"
+	}
+	for _, c := range []*cobra.Command{eventsCmd, nextCmd, refreshCmd, queryRunCmd} {
+		c.Flags().Bool("relative", false, "add a STARTS IN column with the time until each event (table output only)")
+	}
+	nextCmd.Flags().StringP("output", "o", "table", "output format (table, json)")
+	getCmd.Flags().StringP("output", "o", "table", "output format (table, json, ics)")
+	getCmd.Flags().Bool("pretty", false, "with -o ics, refold lines to 75 octets with CRLF endings")
+	editCmd.Flags().Bool("force", false, "edit events from synced calendars")
+	deleteCmd.Flags().BoolP("yes", "y", false, "delete without asking for confirmation")
+	importDirCmd.Flags().BoolP("recursive", "r", false, "also import files in subdirectories")
+	importDirCmd.Flags().Int("workers", 0, "number of files to import at once (default the number of CPUs)")
+	createCmd.Flags().String("summary", "", "event summary")
+	createCmd.Flags().String("start", "", "start time (YYYY-MM-DD HH:MM, or YYYY-MM-DD with --all-day)")
+	createCmd.Flags().String("end", "", "end time, in the same format as --start")
+	createCmd.Flags().String("location", "", "event location")
+	createCmd.Flags().String("description", "", "event description")
+	createCmd.Flags().Bool("all-day", false, "create an all-day event")
+	getCmd.Flags().String("copy", "", "copy the event (or its URL with --copy=url) to the clipboard")
+	getCmd.Flags().Lookup("copy").NoOptDefVal = "event"
+
+	freeCmd.Flags().StringP("output", "o", "table", "output format (table, json)")
+	freeCmd.Flags().Duration("buffer", 0, "keep this much time free before and after each event (e.g. 10m)")
+	busyCmd.Flags().StringP("output", "o", "table", "output format (table, json, ics)")
+	statusCmd.Flags().StringP("output", "o", "table", "output format (table, json)")
+	conflictsCmd.Flags().StringP("output", "o", "table", "output format (table, json)")
+	historyCmd.Flags().StringP("output", "o", "table", "output format (table, json)")
+	historyCmd.Flags().Int("last", 10, "show this many of the most recent syncs (0 for all)")
+	conflictsCmd.Flags().Bool("cross-only", false, "only show conflicts between events of different calendars")
+	listCmd.Flags().Bool("detailed", false, "include event counts, last sync and enabled state")
+	for _, c := range []*cobra.Command{eventsCmd, freeCmd, busyCmd, conflictsCmd, refreshCmd, querySaveCmd} {
+		c.Flags().String("from", "", "start of the range (YYYY-MM-DD or RFC 3339); replaces the positional range")
+		c.Flags().String("to", "", "end of the range (YYYY-MM-DD, inclusive, or RFC 3339)")
+	}
+	versionCmd.Flags().StringP("output", "o", "text", "output format (text, json)")
+	// Every command with JSON output can also write it to a file.
+	for _, c := range []*cobra.Command{listCmd, diffCmd, statusCmd, eventsCmd, queryRunCmd, nextCmd, freeCmd, busyCmd, conflictsCmd, historyCmd, getCmd, versionCmd} {
+		c.Flags().Bool("compact", false, "print JSON on a single line")
+		c.Flags().StringP("output-file", "f", "", "write output to this file instead of stdout")
+	}
+	for _, c := range []*cobra.Command{listCmd, diffCmd, statusCmd, eventsCmd, queryRunCmd, nextCmd, getCmd} {
+		c.Flags().Bool("envelope", false, fmt.Sprintf(`wrap JSON output as {"schema": %d, ...} so scripts can check its version`, calendar.SchemaVersion))
+	}
 
-	rootCmd.AddCommand(addCmd, removeCmd, syncCmd, listCmd, eventsCmd, getCmd)
+	profileCmd.AddCommand(profileListCmd, profileUseCmd, profileCreateCmd)
+	queryCmd.AddCommand(querySaveCmd, queryRunCmd, queryListCmd, queryDeleteCmd)
+	tzCmd.AddCommand(tzListCmd, tzNowCmd)
+	rootCmd.AddCommand(addCmd, removeCmd, disableCmd, enableCmd, mergeCmd, diffCmd, restoreCmd, syncCmd, refreshCmd, listCmd, statusCmd, historyCmd, eventsCmd, nextCmd, freeCmd, busyCmd, conflictsCmd, heatmapCmd, queryCmd, tzCmd, getCmd, openCmd, editCmd, createCmd, importCmd, importDirCmd, deleteCmd, versionCmd, profileCmd)
 }
 
 func main() {
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arjungandhi/calendar"
+)
+
+func TestPrintEventsTableTitleless(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	events := []calendar.Event{
+		{UID: "a", Start: start, Calendar: "work"},
+		{UID: "b", Start: start, Description: "Call with Sam\nDial in at 10", Calendar: "work"},
+	}
+	var out strings.Builder
+	printEventsTable(&out, events, calendar.Layouts{}, time.Time{}, 0)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want a header and 2 rows:\n%s", len(lines), out.String())
+	}
+	for i, want := range []string{"(no title)", "Call with Sam"} {
+		if !strings.Contains(lines[i+1], want) {
+			t.Errorf("row %d = %q, want it to show %q", i+1, lines[i+1], want)
+		}
+	}
+}
+
+func TestParseRangeInLargeOffsetZone(t *testing.T) {
+	loc, err := time.LoadLocation("Pacific/Kiritimati")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2026, 12, 20, 23, 30, 0, 0, loc)
+	from, to, err := parseRange([]string{"2026-12-25"}, "", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 12, 25, 0, 0, 0, 0, loc); !from.Equal(want) {
+		t.Errorf("from = %v, want %v", from, want)
+	}
+	if want := time.Date(2026, 12, 26, 0, 0, 0, 0, loc); !to.Equal(want) {
+		t.Errorf("to = %v, want %v", to, want)
+	}
+	from, _, err = parseRange(nil, "2026-12-25", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2026, 12, 25, 0, 0, 0, 0, loc); !from.Equal(want) {
+		t.Errorf("--from = %v, want %v", from, want)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/arjungandhi/calendar"
+	"github.com/spf13/cobra"
+)
+
+// watchEvents redraws the events table every --interval, syncing first
+// when --sync is set, until interrupted.
+func watchEvents(cmd *cobra.Command, args []string) error {
+	interval, _ := cmd.Flags().GetDuration("interval")
+	if interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	if format, _ := cmd.Flags().GetString("output"); format != "table" {
+		return fmt.Errorf("--watch only supports table output")
+	}
+	if path, _ := cmd.Flags().GetString("output-file"); path != "" {
+		return fmt.Errorf("--watch can't be combined with --output-file")
+	}
+	doSync, _ := cmd.Flags().GetBool("sync")
+
+	mgr, err := newManager(cmd)
+	if err != nil {
+		return err
+	}
+	mgr.Log.Level = calendar.LogQuiet
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var syncErr error
+		if doSync {
+			_, syncErr = mgr.SyncAll(ctx, calendar.SyncOptions{})
+			// Don't redraw once interrupted during a slow sync.
+			if ctx.Err() != nil {
+				return nil
+			}
+		}
+		// Clear the screen and move the cursor home.
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("%s (every %s, ctrl-c to quit)\n\n", mgr.Now().Format("Mon, 02 Jan 2006 15:04:05"), interval)
+		if err := runEvents(cmd, args); err != nil {
+			return err
+		}
+		if syncErr != nil {
+			fmt.Fprintf(os.Stderr, "\nsync failed: %v\n", syncErr)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,27 +1,262 @@
 package calendar
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
-// Config holds the calendar configuration directory path.
+// DefaultProductID is the PRODID written to generated calendars.
+const DefaultProductID = "-//arjungandhi/calendar//EN"
+
+// Event file formats for Config.EventFormat.
+const (
+	// EventFormatNormalized wraps each stored event in a minimal VCALENDAR
+	// with our VERSION and PRODID, the feed's X-WR-TIMEZONE, and the
+	// VTIMEZONEs the event references. Files look the same whatever the
+	// provider, so sync only rewrites events that actually changed.
+	EventFormatNormalized = "normalized"
+	// EventFormatRaw keeps every calendar-level property of the feed,
+	// including its PRODID and vendor X- properties, alongside the
+	// referenced VTIMEZONEs. This is more faithful to the source, but
+	// a provider touching any calendar-level property rewrites every file.
+	EventFormatRaw = "raw"
+)
+
+// layoutPresets are the names accepted in place of a Go layout by
+// Config.DateFormat and Config.TimeFormat.
+var layoutPresets = map[string]Layouts{
+	"iso": {Date: "2006-01-02", Time: "15:04"},
+	"us":  {Date: "01/02/2006", Time: "3:04 PM"},
+	"eu":  {Date: "02.01.2006", Time: "15:04"},
+}
+
+// Config holds the calendar configuration directory path and the optional
+// settings read from config.json inside it.
 type Config struct {
-	Dir string
+	Dir string `json:"-"`
+	// Profile is the profile Dir belongs to (see ProfileDir).
+	Profile string `json:"-"`
+
+	// ProductID is the PRODID of generated calendars.
+	ProductID string `json:"prodid,omitempty"`
+	// CalendarName is published as X-WR-CALNAME on exports.
+	CalendarName string `json:"calendar_name,omitempty"`
+	// Timezone is published as X-WR-TIMEZONE on exports.
+	Timezone string `json:"timezone,omitempty"`
+
+	// MaxRedirects caps how many redirects a sync follows (default 10).
+	MaxRedirects int `json:"max_redirects,omitempty"`
+	// FetchTimeout caps, in seconds, how long fetching one feed may take,
+	// from connecting to reading the last byte (default 60), so a hung
+	// server can't stall a sync.
+	FetchTimeout int `json:"fetch_timeout,omitempty"`
+	// MaxRetryAfter caps, in seconds, how long a sync waits when a feed
+	// answers 429 with a Retry-After before retrying once (default 60).
+	// Feeds asking for longer are skipped.
+	MaxRetryAfter int `json:"max_retry_after,omitempty"`
+	// DisableRedirects stops syncs from following redirects at all.
+	DisableRedirects bool `json:"disable_redirects,omitempty"`
+	// UserAgent overrides the User-Agent header sent when fetching feeds.
+	UserAgent string `json:"user_agent,omitempty"`
+	// Lenient skips malformed events instead of failing the whole feed.
+	Lenient bool `json:"lenient,omitempty"`
+	// CACertFile is a PEM file of extra root certificates to trust when
+	// fetching feeds, such as a corporate proxy's CA.
+	CACertFile string `json:"ca_cert_file,omitempty"`
+	// Insecure disables TLS certificate verification entirely. It is meant
+	// for testing and is only set by the --insecure flag, never from
+	// config.json.
+	Insecure bool `json:"-"`
+	// DefaultTZ is the IANA zone for floating times that carry neither a
+	// TZID nor a trailing Z. The CALENDAR_TZ environment variable overrides
+	// it; when both are empty the host's local zone is used.
+	DefaultTZ string `json:"default_tz,omitempty"`
+	// WorkingDays are the weekdays free-slot search considers (default
+	// Monday to Friday), as numbers with Sunday = 0.
+	WorkingDays []time.Weekday `json:"working_days,omitempty"`
+	// WorkDayStart and WorkDayEnd bound free-slot search each working day,
+	// as HH:MM (default 09:00 to 17:00).
+	WorkDayStart string `json:"work_day_start,omitempty"`
+	WorkDayEnd   string `json:"work_day_end,omitempty"`
+	// MaxBackups is how many sources.json backups to keep (default 5).
+	MaxBackups int `json:"max_backups,omitempty"`
+	// MaxSyncLogKB is how large sync.log grows, in KiB, before it is
+	// rotated to sync.log.1 (default 1024).
+	MaxSyncLogKB int `json:"max_sync_log_kb,omitempty"`
+	// EventFormat selects how synced events are stored: "normalized"
+	// (default) or "raw". See EventFormatNormalized and EventFormatRaw.
+	EventFormat string `json:"event_format,omitempty"`
+	// DateFormat and TimeFormat are Go layouts for displaying dates and
+	// times of day, or one of the presets "iso", "us" and "eu". Unset, each
+	// view keeps its own default.
+	DateFormat string `json:"date_format,omitempty"`
+	TimeFormat string `json:"time_format,omitempty"`
+	// CacheEvents keeps each calendar's parsed events in events.cache.json,
+	// written at the end of every sync, so reads skip parsing every .ics
+	// file while the calendar is unchanged.
+	CacheEvents bool `json:"cache_events,omitempty"`
+	// Email is the user's own address, used to find their RSVP among an
+	// event's attendees.
+	Email string `json:"email,omitempty"`
+
+	location *time.Location
+	layouts  Layouts
 }
 
-// NewConfig creates a new Config. It reads the CALENDAR_DIR environment
-// variable or defaults to ~/.config/calendar.
+// NewConfig creates a new Config for the active profile. The base directory
+// is CALENDAR_DIR or ~/.config/calendar, and the profile comes from
+// ActiveProfile; config.json is loaded from the profile's directory if
+// present.
 func NewConfig() (*Config, error) {
-	dir := os.Getenv("CALENDAR_DIR")
-	if dir == "" {
-		home, err := os.UserHomeDir()
+	base, err := DefaultBaseDir()
+	if err != nil {
+		return nil, err
+	}
+	profile, err := ActiveProfile(base)
+	if err != nil {
+		return nil, err
+	}
+	return LoadProfileConfig(base, profile)
+}
+
+// LoadConfig creates a Config rooted at dir, loading config.json from it if
+// present.
+func LoadConfig(dir string) (*Config, error) {
+	cfg := &Config{Dir: dir}
+	if err := cfg.load(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// load reads config.json and fills in defaults for unset fields.
+func (c *Config) load() error {
+	data, err := os.ReadFile(c.ConfigFile())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, c); err != nil {
+			return err
+		}
+	}
+	if c.ProductID == "" {
+		c.ProductID = DefaultProductID
+	}
+	if tz := os.Getenv("CALENDAR_TZ"); tz != "" {
+		c.DefaultTZ = tz
+	}
+	if c.DefaultTZ != "" {
+		loc, err := LoadTimeZone(c.DefaultTZ)
+		if err != nil {
+			return fmt.Errorf("invalid default timezone: %w", err)
+		}
+		c.location = loc
+	}
+	if c.MaxBackups <= 0 {
+		c.MaxBackups = 5
+	}
+	if c.MaxSyncLogKB <= 0 {
+		c.MaxSyncLogKB = 1024
+	}
+	switch c.EventFormat {
+	case "":
+		c.EventFormat = EventFormatNormalized
+	case EventFormatNormalized, EventFormatRaw:
+	default:
+		return fmt.Errorf("invalid event_format %q (use %s or %s)", c.EventFormat, EventFormatNormalized, EventFormatRaw)
+	}
+	if len(c.WorkingDays) == 0 {
+		c.WorkingDays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+	}
+	if c.WorkDayStart == "" {
+		c.WorkDayStart = "09:00"
+	}
+	if c.WorkDayEnd == "" {
+		c.WorkDayEnd = "17:00"
+	}
+	if _, err := c.WorkingHours(); err != nil {
+		return err
+	}
+	if c.DateFormat != "" {
+		layout, err := resolveLayout("date_format", c.DateFormat, layoutPresets[c.DateFormat].Date)
+		if err != nil {
+			return err
+		}
+		c.layouts.Date = layout
+	}
+	if c.TimeFormat != "" {
+		layout, err := resolveLayout("time_format", c.TimeFormat, layoutPresets[c.TimeFormat].Time)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		dir = filepath.Join(home, ".config", "calendar")
+		c.layouts.Time = layout
+	}
+	return nil
+}
+
+// resolveLayout returns preset if the setting named a preset, otherwise
+// value after checking it is a usable Go layout: one that formats a time
+// into something that parses back.
+func resolveLayout(setting, value, preset string) (string, error) {
+	if preset != "" {
+		return preset, nil
+	}
+	// Not the reference time itself, which every layout formats as-is.
+	ref := time.Date(2009, time.November, 10, 23, 4, 5, 0, time.UTC)
+	s := ref.Format(value)
+	if s == value {
+		return "", fmt.Errorf("invalid %s %q: no date or time elements (use a Go layout or iso, us, eu)", setting, value)
+	}
+	if _, err := time.Parse(value, s); err != nil {
+		return "", fmt.Errorf("invalid %s %q: %w", setting, value, err)
+	}
+	return value, nil
+}
+
+// Layouts returns the configured display layouts. Fields are empty when
+// not configured.
+func (c *Config) Layouts() Layouts {
+	return c.layouts
+}
+
+// WorkingHours returns the configured working days and daily bounds.
+func (c *Config) WorkingHours() (WorkingHours, error) {
+	start, err := parseClock(c.WorkDayStart)
+	if err != nil {
+		return WorkingHours{}, fmt.Errorf("invalid work_day_start: %w", err)
+	}
+	end, err := parseClock(c.WorkDayEnd)
+	if err != nil {
+		return WorkingHours{}, fmt.Errorf("invalid work_day_end: %w", err)
+	}
+	if end <= start {
+		return WorkingHours{}, fmt.Errorf("work_day_end %s must be after work_day_start %s", c.WorkDayEnd, c.WorkDayStart)
+	}
+	return WorkingHours{Days: c.WorkingDays, Start: start, End: end}, nil
+}
+
+// Location returns the zone used for floating times.
+func (c *Config) Location() *time.Location {
+	if c.location != nil {
+		return c.location
 	}
-	return &Config{Dir: dir}, nil
+	return time.Local
+}
+
+// userAgent returns the configured User-Agent or the default
+// arjungandhi-calendar/<version>.
+func (c *Config) userAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	return "arjungandhi-calendar/" + Version
 }
 
 // EnsureDir creates the config directory if it doesn't exist.
@@ -29,17 +264,60 @@ func (c *Config) EnsureDir() error {
 	return os.MkdirAll(c.Dir, 0755)
 }
 
+// ConfigFile returns the path to the config.json file.
+func (c *Config) ConfigFile() string {
+	return filepath.Join(c.Dir, "config.json")
+}
+
 // SourcesFile returns the path to the sources.json file.
 func (c *Config) SourcesFile() string {
 	return filepath.Join(c.Dir, "sources.json")
 }
 
+// QueriesFile returns the path to the queries.json file of saved queries.
+func (c *Config) QueriesFile() string {
+	return filepath.Join(c.Dir, "queries.json")
+}
+
+// SyncLogFile returns the path to sync.log, the JSONL history of syncs.
+func (c *Config) SyncLogFile() string {
+	return filepath.Join(c.Dir, "sync.log")
+}
+
+// LockFile returns the path to the lock file held while syncing.
+func (c *Config) LockFile() string {
+	return filepath.Join(c.Dir, "sync.lock")
+}
+
 // EventsDir returns the path to the events directory.
 func (c *Config) EventsDir() string {
 	return filepath.Join(c.Dir, "events")
 }
 
-// CalendarDir returns the path to a specific calendar's events directory.
+// CalendarDir returns the path to a specific calendar's events directory,
+// named by calendarDirName so it always sits directly inside EventsDir.
 func (c *Config) CalendarDir(name string) string {
-	return filepath.Join(c.EventsDir(), name)
+	return filepath.Join(c.EventsDir(), calendarDirName(name))
+}
+
+// calendarDirName maps a calendar name to a single safe path element. Names
+// without path separators that don't start with a dot are used as they are;
+// others have those characters replaced and a hash of the original name
+// appended, so "Work/Personal" and "Work_Personal" get different
+// directories.
+func calendarDirName(name string) string {
+	safe := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == 0 {
+			return '_'
+		}
+		return r
+	}, name)
+	if strings.HasPrefix(safe, ".") {
+		safe = "_" + safe[1:]
+	}
+	if safe == name && name != "" {
+		return name
+	}
+	sum := sha256.Sum256([]byte(name))
+	return safe + "-" + hex.EncodeToString(sum[:4])
 }
@@ -0,0 +1,52 @@
+package calendar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCalendarDirName(t *testing.T) {
+	seen := map[string]string{}
+	for _, name := range []string{
+		"Work/Personal", "Work_Personal", "..", "../escape", `a\b`, ".hidden", "_hidden", "", "plain",
+	} {
+		dir := calendarDirName(name)
+		if dir == "" || dir == "." || dir == ".." || strings.HasPrefix(dir, ".") || strings.ContainsAny(dir, `/\`) {
+			t.Errorf("calendarDirName(%q) = %q, not a safe single directory", name, dir)
+		}
+		if other, ok := seen[dir]; ok {
+			t.Errorf("%q and %q share directory %q", name, other, dir)
+		}
+		seen[dir] = name
+	}
+	if got := calendarDirName("plain"); got != "plain" {
+		t.Errorf("calendarDirName(plain) = %q, want it unchanged", got)
+	}
+}
+
+func TestCalendarDirStaysUnderEventsDir(t *testing.T) {
+	cfg := &Config{Dir: t.TempDir()}
+	for _, name := range []string{"..", "../..", "../../etc", "a/../../b"} {
+		dir := cfg.CalendarDir(name)
+		rel, err := filepath.Rel(cfg.EventsDir(), dir)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") || strings.ContainsRune(rel, os.PathSeparator) {
+			t.Errorf("CalendarDir(%q) = %s, outside %s", name, dir, cfg.EventsDir())
+		}
+	}
+}
+
+func TestInvalidDefaultTimezoneNamedOnce(t *testing.T) {
+	t.Setenv("CALENDAR_TZ", "America/New_Yrok")
+	_, err := LoadConfig(t.TempDir())
+	if err == nil {
+		t.Fatal("no error for an unknown zone")
+	}
+	if n := strings.Count(err.Error(), `"America/New_Yrok"`); n != 1 {
+		t.Errorf("zone named %d times in %q", n, err)
+	}
+	if !strings.Contains(err.Error(), "America/New_York") {
+		t.Errorf("err = %q, want a suggestion of America/New_York", err)
+	}
+}
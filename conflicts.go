@@ -0,0 +1,91 @@
+package calendar
+
+import (
+	"sort"
+	"time"
+)
+
+// Conflict is a pair of timed events that overlap, First starting no later
+// than Second. Start and End bound the overlap.
+type Conflict struct {
+	First          Event     `json:"first"`
+	Second         Event     `json:"second"`
+	FirstCalendar  string    `json:"first_calendar"`
+	SecondCalendar string    `json:"second_calendar"`
+	SameCalendar   bool      `json:"same_calendar"`
+	Start          time.Time `json:"start"`
+	End            time.Time `json:"end"`
+}
+
+// DetectConflicts returns every pair of events that overlap, ordered by the
+// start of the overlap. All-day and cancelled events, and events without an
+// end, never conflict.
+func DetectConflicts(events []Event) []Conflict {
+	var timed []Event
+	for _, e := range events {
+		if !e.AllDay && e.Status != "CANCELLED" && e.End.After(e.Start) {
+			timed = append(timed, e)
+		}
+	}
+	sort.SliceStable(timed, func(i, j int) bool { return timed[i].Start.Before(timed[j].Start) })
+
+	var conflicts []Conflict
+	for i, a := range timed {
+		for _, b := range timed[i+1:] {
+			if !b.Start.Before(a.End) {
+				break
+			}
+			end := a.End
+			if b.End.Before(end) {
+				end = b.End
+			}
+			conflicts = append(conflicts, Conflict{
+				First:          a,
+				Second:         b,
+				FirstCalendar:  a.Calendar,
+				SecondCalendar: b.Calendar,
+				SameCalendar:   a.Calendar == b.Calendar,
+				Start:          b.Start,
+				End:            end,
+			})
+		}
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool { return conflicts[i].Start.Before(conflicts[j].Start) })
+	return conflicts
+}
+
+// CrossCalendarConflicts keeps the conflicts between events of different
+// calendars, which are usually the real double-bookings.
+func CrossCalendarConflicts(conflicts []Conflict) []Conflict {
+	var out []Conflict
+	for _, c := range conflicts {
+		if !c.SameCalendar {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Conflicts returns the conflicts between events whose overlap falls at
+// least partly between from and to.
+func (m *CalendarManager) Conflicts(from, to time.Time) ([]Conflict, error) {
+	events, err := m.overlappingEvents(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var out []Conflict
+	for _, c := range DetectConflicts(events) {
+		if c.End.After(from) && (to.IsZero() || c.Start.Before(to)) {
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+// FormatConflictsJSON returns conflicts as JSON, indented unless compact.
+func FormatConflictsJSON(conflicts []Conflict, compact bool) (string, error) {
+	if conflicts == nil {
+		conflicts = []Conflict{}
+	}
+	return marshalJSON(conflicts, compact)
+}
@@ -0,0 +1,158 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// DiffReport describes what syncing a calendar would change, without
+// changing anything.
+type DiffReport struct {
+	Calendar  string      `json:"calendar"`
+	Added     []DiffEntry `json:"added"`
+	Removed   []DiffEntry `json:"removed"`
+	Modified  []DiffEntry `json:"modified"`
+	Unchanged int         `json:"unchanged"`
+}
+
+// DiffEntry is one event in a DiffReport. Changes names the fields of a
+// modified event that differ; it is empty when only other properties, such
+// as DTSTAMP, changed.
+type DiffEntry struct {
+	UID     string   `json:"uid"`
+	Summary string   `json:"summary"`
+	Changes []string `json:"changes,omitempty"`
+}
+
+// DiffSource fetches the feeds of the calendar name and compares their
+// events with the stored ones by UID, as syncing with opts would, without
+// writing anything. opts.IfStale is ignored.
+func (m *CalendarManager) DiffSource(ctx context.Context, name string, opts SyncOptions) (DiffReport, error) {
+	report := DiffReport{Calendar: name, Added: []DiffEntry{}, Removed: []DiffEntry{}, Modified: []DiffEntry{}}
+	sources, err := m.LoadSources()
+	if err != nil {
+		return report, err
+	}
+	i, err := findSource(sources, name)
+	if err != nil {
+		return report, err
+	}
+	if i < 0 {
+		return report, fmt.Errorf("calendar %q not found", name)
+	}
+	s := sources[i]
+	name = s.Name
+	report.Calendar = name
+	if s.Local() {
+		return report, fmt.Errorf("calendar %q is local and has no feed to compare with", name)
+	}
+
+	incoming, _, feedErrs, err := m.fetchSource(ctx, s)
+	if err != nil {
+		return report, err
+	}
+	if len(feedErrs) > 0 {
+		// Without all the feeds, events would wrongly show as removed.
+		return report, feedErrs[0]
+	}
+	existing, err := m.storedEvents(name)
+	if err != nil {
+		return report, err
+	}
+	if len(incoming) == 0 && len(existing) > 0 && !opts.AllowEmpty {
+		m.Log.Errorf("warning: %s returned no events; syncing would keep the %d stored ones (use --allow-empty to clear them)\n",
+			name, len(existing))
+		report.Unchanged = len(existing)
+		return report, nil
+	}
+
+	loc := m.Config.Location()
+	summary := func(data []byte) string {
+		if e, err := readEvent(data, name, loc); err == nil {
+			return e.Summary
+		}
+		return ""
+	}
+	for uid, data := range incoming {
+		old, ok := existing[uid]
+		switch {
+		case !ok:
+			report.Added = append(report.Added, DiffEntry{UID: uid, Summary: summary(data)})
+		case bytes.Equal(old, data):
+			report.Unchanged++
+		default:
+			entry := DiffEntry{UID: uid, Summary: summary(data)}
+			oldEvent, err1 := readEvent(old, name, loc)
+			newEvent, err2 := readEvent(data, name, loc)
+			if err1 == nil && err2 == nil {
+				entry.Changes = changedFields(oldEvent, newEvent)
+			}
+			report.Modified = append(report.Modified, entry)
+		}
+	}
+	for uid, data := range existing {
+		if _, ok := incoming[uid]; !ok {
+			report.Removed = append(report.Removed, DiffEntry{UID: uid, Summary: summary(data)})
+		}
+	}
+	for _, entries := range [][]DiffEntry{report.Added, report.Removed, report.Modified} {
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Summary != entries[j].Summary {
+				return entries[i].Summary < entries[j].Summary
+			}
+			return entries[i].UID < entries[j].UID
+		})
+	}
+	return report, nil
+}
+
+// changedFields names the user-visible fields that differ between a and b.
+func changedFields(a, b *Event) []string {
+	var changed []string
+	check := func(name string, differ bool) {
+		if differ {
+			changed = append(changed, name)
+		}
+	}
+	check("summary", a.Summary != b.Summary)
+	check("start", !a.Start.Equal(b.Start) || a.AllDay != b.AllDay)
+	check("end", !a.End.Equal(b.End))
+	check("location", a.Location != b.Location)
+	check("description", a.Description != b.Description)
+	check("status", a.Status != b.Status)
+	check("recurrence", a.Recurrence != b.Recurrence)
+	check("url", a.URL != b.URL)
+	check("categories", !slices.Equal(a.Categories, b.Categories))
+	return changed
+}
+
+// FormatDiffJSON returns a DiffReport as JSON, indented unless compact.
+func FormatDiffJSON(r DiffReport, compact bool) (string, error) {
+	return marshalJSON(r, compact)
+}
+
+// FormatDiff renders a DiffReport as +, - and ~ lines, one per event,
+// followed by a count of each kind of change.
+func FormatDiff(r DiffReport) string {
+	var b strings.Builder
+	for _, e := range r.Added {
+		fmt.Fprintf(&b, "+ %s (%s)\n", e.Summary, e.UID)
+	}
+	for _, e := range r.Removed {
+		fmt.Fprintf(&b, "- %s (%s)\n", e.Summary, e.UID)
+	}
+	for _, e := range r.Modified {
+		fmt.Fprintf(&b, "~ %s (%s)", e.Summary, e.UID)
+		if len(e.Changes) > 0 {
+			fmt.Fprintf(&b, ": %s", strings.Join(e.Changes, ", "))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%s: %d added, %d modified, %d removed, %d unchanged\n",
+		r.Calendar, len(r.Added), len(r.Modified), len(r.Removed), r.Unchanged)
+	return b.String()
+}
@@ -0,0 +1,58 @@
+package calendar
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDiffSourceIgnoresCase(t *testing.T) {
+	m := newTestManager(t)
+	srv := serveICS(t, testFeed)
+	if err := m.AddSource(Source{Name: "Work", URL: srv.URL}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	report, err := m.DiffSource(context.Background(), "work", SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Calendar != "Work" {
+		t.Errorf("report.Calendar = %q, want the stored name", report.Calendar)
+	}
+	if len(report.Added) != 1 || report.Added[0].UID != "one" {
+		t.Errorf("added = %+v, want the feed's event", report.Added)
+	}
+	if _, err := m.DiffSource(context.Background(), "missing", SyncOptions{}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("err = %v, want not found", err)
+	}
+}
+
+func TestDiffSourceEmptyFeed(t *testing.T) {
+	m := newTestManager(t)
+	syncFeed(t, m, testFeed)
+	empty := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nEND:VCALENDAR\r\n"
+	srv := serveICS(t, empty)
+	sources, err := m.LoadSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources[0].URL = srv.URL
+	if err := m.SaveSources(sources); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := m.DiffSource(context.Background(), "feed", SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Removed) != 0 || report.Unchanged != 1 {
+		t.Errorf("removed %d, unchanged %d; want the stored event kept, as sync would", len(report.Removed), report.Unchanged)
+	}
+	report, err = m.DiffSource(context.Background(), "feed", SyncOptions{AllowEmpty: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Removed) != 1 {
+		t.Errorf("with AllowEmpty removed %d, want 1", len(report.Removed))
+	}
+}
@@ -0,0 +1,198 @@
+package calendar
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// IsReadOnly reports whether calName is synced from a feed, in which case
+// local changes are overwritten by the next sync.
+func (m *CalendarManager) IsReadOnly(calName string) (bool, error) {
+	sources, err := m.LoadSources()
+	if err != nil {
+		return false, err
+	}
+	i, err := findSource(sources, calName)
+	if err != nil {
+		return false, err
+	}
+	if i < 0 {
+		return false, fmt.Errorf("calendar %q not found", calName)
+	}
+	return !sources[i].Local(), nil
+}
+
+// UpdateEvent replaces the summary, times, location and description of the
+// stored event uid with those of e, bumping its SEQUENCE and LAST-MODIFIED.
+// RECURRENCE-ID overrides of the event are left as they are.
+func (m *CalendarManager) UpdateEvent(uid string, e Event) error {
+	old, raw, err := m.GetEvent(uid)
+	if err != nil {
+		return err
+	}
+	cal, err := ical.NewDecoder(strings.NewReader(raw)).Decode()
+	if err != nil {
+		return fmt.Errorf("reading event %q: %w", uid, err)
+	}
+
+	var master *ical.Component
+	for _, ie := range cal.Events() {
+		if ie.Props.Get(ical.PropRecurrenceID) == nil {
+			master = ie.Component
+			break
+		}
+	}
+	if master == nil {
+		return fmt.Errorf("event %q has no master VEVENT", uid)
+	}
+
+	setOptionalText(master.Props, ical.PropSummary, e.Summary)
+	setOptionalText(master.Props, ical.PropLocation, e.Location)
+	setOptionalText(master.Props, ical.PropDescription, e.Description)
+	setEventTime(master.Props, ical.PropDateTimeStart, e.Start, e.AllDay)
+	if e.End.IsZero() {
+		master.Props.Del(ical.PropDateTimeEnd)
+	} else {
+		setEventTime(master.Props, ical.PropDateTimeEnd, e.End, e.AllDay)
+	}
+	seq := ical.NewProp(ical.PropSequence)
+	seq.Value = strconv.Itoa(old.Sequence + 1)
+	master.Props.Set(seq)
+	now := m.Now().UTC().Truncate(time.Second)
+	master.Props.SetDateTime(ical.PropLastModified, now)
+	master.Props.SetDateTime(ical.PropDateTimeStamp, now)
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		return fmt.Errorf("encoding event %q: %w", uid, err)
+	}
+	return m.Store.SaveEvent(old.Calendar, uid, buf.Bytes())
+}
+
+// setOptionalText sets a text property, removing it when value is empty.
+func setOptionalText(props ical.Props, name, value string) {
+	if value == "" {
+		props.Del(name)
+		return
+	}
+	props.SetText(name, value)
+}
+
+// setEventTime writes a DTSTART or DTEND value, as a DATE for all-day
+// events. Times in the host's local zone are written as UTC because "Local"
+// is not a valid TZID.
+func setEventTime(props ical.Props, name string, t time.Time, allDay bool) {
+	prop := ical.NewProp(name)
+	switch {
+	case allDay:
+		prop.SetDate(t)
+	case t.Location() == time.Local:
+		prop.SetDateTime(t.UTC())
+	default:
+		prop.SetDateTime(t)
+	}
+	props.Set(prop)
+}
+
+// CreateEvent stores e as a new event in the local calendar calName and
+// returns its generated UID. The calendar is created if it doesn't exist;
+// calendars synced from a feed are refused since sync would delete the event.
+func (m *CalendarManager) CreateEvent(calName string, e Event) (string, error) {
+	if calName == "" {
+		return "", fmt.Errorf("calendar name is required")
+	}
+	if e.Start.IsZero() {
+		return "", fmt.Errorf("event start is required")
+	}
+	if !e.End.IsZero() && e.End.Before(e.Start) {
+		return "", fmt.Errorf("event end %s is before its start %s", e.End, e.Start)
+	}
+	calName, err := m.ensureLocalCalendar(calName)
+	if err != nil {
+		return "", err
+	}
+
+	uid, err := newUID()
+	if err != nil {
+		return "", err
+	}
+	now := m.Now().UTC().Truncate(time.Second)
+	event := ical.NewEvent()
+	event.Props.SetText(ical.PropUID, uid)
+	event.Props.SetDateTime(ical.PropDateTimeStamp, now)
+	event.Props.SetDateTime(ical.PropCreated, now)
+	event.Props.SetDateTime(ical.PropLastModified, now)
+	setOptionalText(event.Props, ical.PropSummary, e.Summary)
+	setOptionalText(event.Props, ical.PropLocation, e.Location)
+	setOptionalText(event.Props, ical.PropDescription, e.Description)
+	setEventTime(event.Props, ical.PropDateTimeStart, e.Start, e.AllDay)
+	if !e.End.IsZero() {
+		setEventTime(event.Props, ical.PropDateTimeEnd, e.End, e.AllDay)
+	}
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, m.Config.ProductID)
+	cal.Children = append(cal.Children, event.Component)
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		return "", fmt.Errorf("encoding event: %w", err)
+	}
+	if err := m.Store.SaveEvent(calName, uid, buf.Bytes()); err != nil {
+		return "", err
+	}
+	return uid, nil
+}
+
+// ensureLocalCalendar adds calName as a local calendar if it doesn't exist,
+// refusing calendars synced from a feed since sync would delete new events.
+// Names match as findSource does, and the name of the calendar as stored is
+// returned.
+func (m *CalendarManager) ensureLocalCalendar(calName string) (string, error) {
+	if calName == "" {
+		return "", fmt.Errorf("calendar name is required")
+	}
+	sources, err := m.LoadSources()
+	if err != nil {
+		return "", err
+	}
+	i, err := findSource(sources, calName)
+	if err != nil {
+		return "", err
+	}
+	if i >= 0 {
+		if !sources[i].Local() {
+			return "", fmt.Errorf("calendar %q is synced from a feed; create events in a local calendar", sources[i].Name)
+		}
+		return sources[i].Name, nil
+	}
+	return calName, m.SaveSources(append(sources, Source{Name: calName}))
+}
+
+// newUID returns a random UID in the form <hex>@arjungandhi-calendar.
+func newUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b) + "@arjungandhi-calendar", nil
+}
+
+// DeleteEvent removes the stored event uid, including any RECURRENCE-ID
+// overrides stored with it. Events in synced calendars come back on the
+// next sync.
+func (m *CalendarManager) DeleteEvent(uid string) error {
+	event, _, err := m.GetEvent(uid)
+	if err != nil {
+		return err
+	}
+	return m.Store.DeleteEvent(event.Calendar, uid)
+}
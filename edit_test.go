@@ -0,0 +1,41 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateEventMatchesCalendarIgnoringCase(t *testing.T) {
+	m := newTestManager(t)
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	if _, err := m.CreateEvent("Personal", Event{Summary: "First", Start: start}); err != nil {
+		t.Fatal(err)
+	}
+	uid, err := m.CreateEvent("personal", Event{Summary: "Second", Start: start})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sources, err := m.LoadSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 1 || sources[0].Name != "Personal" {
+		t.Errorf("sources = %+v, want only Personal", sources)
+	}
+	e, _, err := m.GetEvent(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Calendar != "Personal" {
+		t.Errorf("event stored in %q, want Personal", e.Calendar)
+	}
+
+	srv := serveICS(t, testFeed)
+	if err := m.AddSource(Source{Name: "Work", URL: srv.URL}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.CreateEvent("work", Event{Summary: "Third", Start: start}); err == nil || !strings.Contains(err.Error(), "synced from a feed") {
+		t.Errorf("creating in a feed calendar by another case: err = %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package calendar
+
+import (
+	"slices"
+	"time"
+)
+
+// expansionHorizonYears is how many years past now recurring events are
+// expanded when a range has no end.
+const expansionHorizonYears = 1
+
+// expandRecurrences replaces each recurring master in events with its
+// instances starting in [from, to): those generated by its RRULE plus any
+// extra RDATEs. Instances listed in EXDATE or replaced by a RECURRENCE-ID
+// override are skipped; the overrides themselves are kept as they are.
+// Masters whose rule can't be expanded are kept as the base event. An open
+// to ends expansionHorizonYears after the later of from and now.
+func expandRecurrences(events []Event, from, to, now time.Time) []Event {
+	if to.IsZero() {
+		start := now
+		if from.After(start) {
+			start = from
+		}
+		to = start.AddDate(expansionHorizonYears, 0, 0)
+	}
+
+	key := func(e Event) string { return e.Calendar + "\x00" + e.UID }
+	overridden := make(map[string][]time.Time)
+	for _, e := range events {
+		if !e.RecurrenceID.IsZero() {
+			overridden[key(e)] = append(overridden[key(e)], e.RecurrenceID)
+		}
+	}
+
+	var out []Event
+	for _, e := range events {
+		if (e.Recurrence == "" && len(e.rdates) == 0) || !e.RecurrenceID.IsZero() {
+			out = append(out, e)
+			continue
+		}
+		var starts []time.Time
+		if e.Recurrence != "" {
+			var ok bool
+			if starts, ok = expandRRULE(e.Recurrence, e.Start, from, to); !ok {
+				out = append(out, e)
+				continue
+			}
+		} else if (from.IsZero() || !e.Start.Before(from)) && e.Start.Before(to) {
+			// DTSTART is always an instance, even with only RDATEs.
+			starts = []time.Time{e.Start}
+		}
+		for _, rd := range e.rdates {
+			start := rdateStart(e, rd)
+			if (from.IsZero() || !start.Before(from)) && start.Before(to) && !containsTime(starts, start) {
+				starts = append(starts, start)
+			}
+		}
+		slices.SortFunc(starts, time.Time.Compare)
+
+		skip := overridden[key(e)]
+		for _, start := range starts {
+			if excluded(start, e.exdates) || containsTime(skip, start) {
+				continue
+			}
+			out = append(out, instanceAt(e, start))
+		}
+	}
+	return out
+}
+
+// rdateStart returns the instance start an RDATE adds. A date-only RDATE on
+// a timed event keeps the master's time of day.
+func rdateStart(master Event, rd eventDate) time.Time {
+	if !rd.allDay || master.AllDay {
+		return rd.t
+	}
+	y, m, d := rd.t.Date()
+	h, mi, s := master.Start.Clock()
+	return time.Date(y, m, d, h, mi, s, 0, master.Start.Location())
+}
+
+// instanceAt returns the instance of master that starts at start, keeping
+// the master's duration. Timed events keep the exact duration, as RFC 5545
+// requires for DTEND; all-day events keep their length in calendar days, so
+// an instance spanning a DST change doesn't end an hour into the wrong day.
+func instanceAt(master Event, start time.Time) Event {
+	inst := master
+	inst.Start = start
+	inst.RecurrenceID = start
+	switch {
+	case master.End.IsZero():
+	case master.AllDay:
+		sy, sm, sd := master.Start.Date()
+		ey, em, ed := master.End.Date()
+		days := time.Date(ey, em, ed, 0, 0, 0, 0, time.UTC).Sub(time.Date(sy, sm, sd, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour)
+		inst.End = start.AddDate(0, 0, int(days))
+	default:
+		inst.End = start.Add(master.End.Sub(master.Start))
+	}
+	return inst
+}
+
+// excluded reports whether start matches an EXDATE. Date-only EXDATEs
+// exclude every instance on that day.
+func excluded(start time.Time, exdates []eventDate) bool {
+	for _, ex := range exdates {
+		if ex.allDay {
+			y, m, d := start.Date()
+			ey, em, ed := ex.t.Date()
+			if y == ey && m == em && d == ed {
+				return true
+			}
+		} else if ex.t.Equal(start) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsTime reports whether times holds an instant equal to t.
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, u := range times {
+		if u.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
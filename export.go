@@ -0,0 +1,190 @@
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// ExportOptions controls what ExportICS includes.
+type ExportOptions struct {
+	// IncludeCancelled keeps events whose STATUS is CANCELLED. Cancelled
+	// RECURRENCE-ID overrides are always kept, since subscribers need them
+	// to remove the cancelled occurrence.
+	IncludeCancelled bool
+}
+
+// ExportICS combines the stored VEVENTs for events into a single calendar
+// stamped with the configured PRODID, X-WR-CALNAME and X-WR-TIMEZONE, along
+// with the VTIMEZONEs they reference. Each stored event is included once,
+// however many of its instances are in events.
+func (m *CalendarManager) ExportICS(events []Event, opts ExportOptions) (string, error) {
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, m.Config.ProductID)
+	if m.Config.CalendarName != "" {
+		setExtendedText(cal.Props, "X-WR-CALNAME", m.Config.CalendarName)
+	}
+	if m.Config.Timezone != "" {
+		setExtendedText(cal.Props, "X-WR-TIMEZONE", m.Config.Timezone)
+	}
+
+	stored := make(map[string]map[string][]byte)
+	added := make(map[string]bool)
+	timezones := make(map[string]bool)
+	var tzComps, eventComps []*ical.Component
+	for _, e := range events {
+		key := e.Calendar + "\x00" + e.UID
+		if added[key] {
+			continue
+		}
+		added[key] = true
+		raws, ok := stored[e.Calendar]
+		if !ok {
+			var err error
+			raws, err = m.storedEvents(e.Calendar)
+			if err != nil {
+				return "", err
+			}
+			stored[e.Calendar] = raws
+		}
+		data, ok := raws[e.UID]
+		if !ok {
+			continue
+		}
+		eventCal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+		if err != nil {
+			continue
+		}
+		for _, child := range eventCal.Children {
+			if child.Name != ical.CompTimezone {
+				continue
+			}
+			if tzid, err := child.Props.Text(ical.PropTimezoneID); err == nil && !timezones[tzid] {
+				timezones[tzid] = true
+				tzComps = append(tzComps, child)
+			}
+		}
+		for _, ie := range eventCal.Events() {
+			if !opts.IncludeCancelled && isCancelled(&ie) && ie.Props.Get(ical.PropRecurrenceID) == nil {
+				continue
+			}
+			eventComps = append(eventComps, ie.Component)
+		}
+	}
+	cal.Children = append(tzComps, eventComps...)
+
+	var b strings.Builder
+	if err := ical.NewEncoder(&b).Encode(cal); err != nil {
+		return "", err
+	}
+	return normalizeCRLF(b.String()), nil
+}
+
+// EventICS returns e's stored event, with any RECURRENCE-ID overrides, as
+// a calendar exactly like ExportICS would for it, cancelled or not, so
+// get -o ics and events -o ics agree.
+func (m *CalendarManager) EventICS(e Event) (string, error) {
+	return m.ExportICS([]Event{e}, ExportOptions{IncludeCancelled: true})
+}
+
+// ExportFreeBusy returns a calendar holding a single VFREEBUSY for [from, to)
+// that lists the BusyPeriods as FREEBUSY values, without any event details.
+func (m *CalendarManager) ExportFreeBusy(from, to time.Time) (string, error) {
+	periods, err := m.BusyPeriods(from, to)
+	if err != nil {
+		return "", err
+	}
+	uid, err := newUID()
+	if err != nil {
+		return "", err
+	}
+
+	fb := ical.NewComponent(ical.CompFreeBusy)
+	fb.Props.SetText(ical.PropUID, uid)
+	fb.Props.SetDateTime(ical.PropDateTimeStamp, m.Now().UTC().Truncate(time.Second))
+	fb.Props.SetDateTime(ical.PropDateTimeStart, from.UTC())
+	fb.Props.SetDateTime(ical.PropDateTimeEnd, to.UTC())
+	for _, p := range periods {
+		prop := ical.NewProp(ical.PropFreeBusy)
+		prop.Params.Set("FBTYPE", "BUSY")
+		prop.Value = p.Start.UTC().Format("20060102T150405Z") + "/" + p.End.UTC().Format("20060102T150405Z")
+		fb.Props.Add(prop)
+	}
+
+	cal := ical.NewCalendar()
+	cal.Props.SetText(ical.PropVersion, "2.0")
+	cal.Props.SetText(ical.PropProductID, m.Config.ProductID)
+	cal.Props.SetText(ical.PropMethod, "PUBLISH")
+	cal.Children = append(cal.Children, fb)
+
+	var b strings.Builder
+	if err := ical.NewEncoder(&b).Encode(cal); err != nil {
+		return "", err
+	}
+	return normalizeCRLF(b.String()), nil
+}
+
+// isCancelled reports whether the event's STATUS is CANCELLED.
+func isCancelled(ie *ical.Event) bool {
+	status, _ := ie.Props.Text(ical.PropStatus)
+	return strings.EqualFold(status, "CANCELLED")
+}
+
+// setExtendedText sets a non-standard text property without the
+// VALUE=TEXT parameter go-ical adds to unknown properties.
+func setExtendedText(props ical.Props, name, value string) {
+	prop := ical.NewProp(name)
+	prop.SetText(value)
+	prop.Params.Del(ical.ParamValue)
+	props.Set(prop)
+}
+
+// normalizeCRLF rewrites every line ending in s, bare LF or lone CR, as
+// CRLF.
+func normalizeCRLF(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// CanonicalizeICS unfolds raw iCalendar data and folds it again so every
+// line is at most 75 octets, without splitting UTF-8 characters, with CRLF
+// line endings throughout as RFC 5545 requires.
+func CanonicalizeICS(raw string) string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+	var lines []string
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	var b strings.Builder
+	for _, line := range lines {
+		// Continuation lines start with a space, which counts toward
+		// their 75 octets.
+		limit := 75
+		for len(line) > limit {
+			cut := limit
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			b.WriteString(line[:cut])
+			b.WriteString("\r\n ")
+			line = line[cut:]
+			limit = 74
+		}
+		b.WriteString(line)
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
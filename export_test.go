@@ -0,0 +1,273 @@
+package calendar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// decodeExport parses exported ICS and returns its events.
+func decodeExport(t *testing.T, data string) []ical.Event {
+	t.Helper()
+	cal, err := ical.NewDecoder(strings.NewReader(data)).Decode()
+	if err != nil {
+		t.Fatalf("decoding export: %v\n%s", err, data)
+	}
+	return cal.Events()
+}
+
+func TestExportEscapesTextRoundTrip(t *testing.T) {
+	const text = "Lunch; with A, B\nand notes"
+	m := newTestManager(t)
+	start := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	uid, err := m.CreateEvent("personal", Event{Summary: text, Description: text, Location: text, Start: start, End: start.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _, err := m.GetEvent(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.ExportICS([]Event{*e}, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range strings.Split(data, "\r\n") {
+		if strings.HasPrefix(line, "SUMMARY:") && line != `SUMMARY:Lunch\; with A\, B\nand notes` {
+			t.Errorf("SUMMARY not escaped: %q", line)
+		}
+	}
+	events := decodeExport(t, data)
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	for _, prop := range []string{ical.PropSummary, ical.PropDescription, ical.PropLocation} {
+		got, err := events[0].Props.Text(prop)
+		if err != nil || got != text {
+			t.Errorf("%s round-tripped as %q (%v), want %q", prop, got, err, text)
+		}
+	}
+}
+
+func TestExportEscapesSyncedTextRoundTrip(t *testing.T) {
+	feed := strings.Replace(testFeed, "SUMMARY:One", `SUMMARY:Lunch\; with A\, B\nand notes`, 1)
+	m := newTestManager(t)
+	syncFeed(t, m, feed)
+	e, _, err := m.GetEvent("one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Summary != "Lunch; with A, B\nand notes" {
+		t.Errorf("parsed summary %q", e.Summary)
+	}
+	data, err := m.ExportICS([]Event{*e}, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := decodeExport(t, data)[0].Props.Text(ical.PropSummary)
+	if got != e.Summary {
+		t.Errorf("exported summary %q, want %q", got, e.Summary)
+	}
+}
+
+// syncFeed adds a source serving body and syncs it.
+func syncFeed(t *testing.T, m *CalendarManager, body string) {
+	t.Helper()
+	srv := serveICS(t, body)
+	if err := m.AddSource(Source{Name: "feed", URL: srv.URL}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SyncAll(context.Background(), SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const cancellationFeed = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+	"BEGIN:VEVENT\r\nUID:standup\r\nDTSTAMP:20260101T000000Z\r\nDTSTART:20260105T090000Z\r\nDTEND:20260105T091500Z\r\nRRULE:FREQ=DAILY;COUNT=5\r\nSUMMARY:Standup\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:standup\r\nDTSTAMP:20260101T000000Z\r\nRECURRENCE-ID:20260107T090000Z\r\nDTSTART:20260107T090000Z\r\nDTEND:20260107T091500Z\r\nSTATUS:CANCELLED\r\nSUMMARY:Standup\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:party\r\nDTSTAMP:20260101T000000Z\r\nDTSTART:20260106T180000Z\r\nDTEND:20260106T200000Z\r\nSTATUS:CANCELLED\r\nSUMMARY:Party\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestExportKeepsCancelledOverride(t *testing.T) {
+	m := newTestManager(t)
+	syncFeed(t, m, cancellationFeed)
+	events, err := m.ListEvents(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		includeCancelled bool
+		wantParty        bool
+	}{
+		{false, false},
+		{true, true},
+	} {
+		data, err := m.ExportICS(events, ExportOptions{IncludeCancelled: tc.includeCancelled})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var masters, overrides, party int
+		for _, ie := range decodeExport(t, data) {
+			uid, _ := ie.Props.Text(ical.PropUID)
+			switch {
+			case uid == "party":
+				party++
+			case ie.Props.Get(ical.PropRecurrenceID) != nil:
+				overrides++
+				if !isCancelled(&ie) {
+					t.Errorf("override lost STATUS:CANCELLED")
+				}
+				if rid := ie.Props.Get(ical.PropRecurrenceID).Value; rid != "20260107T090000Z" {
+					t.Errorf("override RECURRENCE-ID = %q", rid)
+				}
+			default:
+				masters++
+			}
+		}
+		if masters != 1 || overrides != 1 {
+			t.Errorf("IncludeCancelled=%v: got %d masters and %d overrides, want 1 each", tc.includeCancelled, masters, overrides)
+		}
+		if (party == 1) != tc.wantParty {
+			t.Errorf("IncludeCancelled=%v: cancelled event exported %d times", tc.includeCancelled, party)
+		}
+	}
+}
+
+func TestCanonicalizeICSFoldsLongLines(t *testing.T) {
+	// 100 ASCII characters, then multi-byte ones that mustn't be split.
+	summary := strings.Repeat("a", 100) + strings.Repeat("é", 40)
+	raw := "BEGIN:VCALENDAR\nBEGIN:VEVENT\nSUMMARY:" + summary[:30] + "\r\n " + summary[30:] + "\nEND:VEVENT\nEND:VCALENDAR\n"
+	out := CanonicalizeICS(raw)
+
+	if strings.Contains(strings.ReplaceAll(out, "\r\n", ""), "\n") {
+		t.Errorf("bare LF left in output:\n%q", out)
+	}
+	if !strings.HasSuffix(out, "END:VCALENDAR\r\n") {
+		t.Errorf("output doesn't end with CRLF: %q", out)
+	}
+	lines := strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n")
+	var unfolded strings.Builder
+	for i, line := range lines {
+		if len(line) > 75 {
+			t.Errorf("line %d is %d octets: %q", i, len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line %d splits a character: %q", i, line)
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded.WriteString(line[1:])
+		} else {
+			unfolded.WriteString("\n" + line)
+		}
+	}
+	if !strings.Contains(unfolded.String(), "\nSUMMARY:"+summary+"\n") {
+		t.Errorf("SUMMARY doesn't unfold to the original:\n%s", unfolded.String())
+	}
+
+	// Canonical output is left as it is.
+	if again := CanonicalizeICS(out); again != out {
+		t.Errorf("canonicalizing twice changed the output:\n%q\n%q", out, again)
+	}
+}
+
+// assertCRLF fails unless every line of data ends in CRLF.
+func assertCRLF(t *testing.T, what, data string) {
+	t.Helper()
+	if !strings.HasSuffix(data, "\r\n") {
+		t.Errorf("%s doesn't end in CRLF", what)
+	}
+	if strings.Contains(strings.ReplaceAll(data, "\r\n", ""), "\n") || strings.Contains(strings.ReplaceAll(data, "\r\n", ""), "\r") {
+		t.Errorf("%s has a bare LF or CR: %q", what, data)
+	}
+}
+
+func TestNormalizeCRLF(t *testing.T) {
+	for in, want := range map[string]string{
+		"a\nb\n":      "a\r\nb\r\n",
+		"a\r\nb\r\n":  "a\r\nb\r\n",
+		"a\rb\r":      "a\r\nb\r\n",
+		"a\r\nb\nc\r": "a\r\nb\r\nc\r\n",
+	} {
+		if got := normalizeCRLF(in); got != want {
+			t.Errorf("normalizeCRLF(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestOutputUsesCRLF(t *testing.T) {
+	// A feed with bare LF line endings throughout.
+	feed := strings.ReplaceAll(recurringFeed, "\r\n", "\n")
+	m := newTestManager(t)
+	syncFeed(t, m, feed)
+
+	files, err := filepath.Glob(filepath.Join(m.Config.CalendarDir("feed"), "*.ics"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no stored events: %v", err)
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assertCRLF(t, filepath.Base(path), string(data))
+	}
+
+	events, err := m.ListEvents(time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := m.ExportICS(events, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCRLF(t, "export", data)
+	data, err = m.GetEventICS("weekly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCRLF(t, "get -o ics", data)
+	data, err = m.ExportFreeBusy(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCRLF(t, "free/busy export", data)
+}
+
+func TestEventICSMatchesExport(t *testing.T) {
+	m := newTestManager(t)
+	m.Config.CalendarName = "Work"
+	syncFeed(t, m, cancellationFeed)
+	events, err := m.ListEvents(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var standup []Event
+	for _, e := range events {
+		if e.UID == "standup" {
+			standup = append(standup, e)
+		}
+	}
+	if len(standup) == 0 {
+		t.Fatal("no standup instances listed")
+	}
+
+	// What events -o ics writes for the standup's instances.
+	exported, err := m.ExportICS(standup, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := m.GetEventICS("standup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != exported {
+		t.Errorf("get -o ics differs from events -o ics:\n%s\nwant:\n%s", got, exported)
+	}
+}
@@ -0,0 +1,470 @@
+package calendar
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// Version is the version reported in the User-Agent header. It can be set
+// at build time with -ldflags "-X github.com/arjungandhi/calendar.Version=...".
+var Version = "dev"
+
+// DefaultMaxRedirects is the redirect limit used when Config leaves it unset.
+const DefaultMaxRedirects = 10
+
+// DefaultFetchTimeout is how long, in seconds, fetching a feed may take
+// when Config leaves FetchTimeout unset.
+const DefaultFetchTimeout = 60
+
+// DefaultMaxRetryAfter is the longest Retry-After wait, in seconds, honored
+// when Config leaves it unset.
+const DefaultMaxRetryAfter = 60
+
+// RateLimitError is returned when a feed answers 429 Too Many Requests.
+// RetryAfter is the wait its Retry-After header asked for, or zero if it
+// gave none.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited (HTTP 429, retry after %s)", e.RetryAfter)
+	}
+	return "rate limited (HTTP 429)"
+}
+
+// parseRetryAfter parses a Retry-After value, either delay seconds or an
+// HTTP date, into a wait from now. Dates in the past give zero.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	v = strings.TrimSpace(v)
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now).Round(time.Second)
+	}
+	return 0
+}
+
+// FetchEvents downloads the iCal feed at url and parses it into events
+// without writing anything to disk. Floating times use the local zone.
+func FetchEvents(url string) ([]Event, error) {
+	client := &http.Client{Timeout: DefaultFetchTimeout * time.Second}
+	f, err := fetchCalendar(client, &Config{}, url)
+	if err != nil {
+		return nil, err
+	}
+	return calendarEvents(f.Calendar, "", time.Local), nil
+}
+
+// ParseEvents decodes an iCal stream into events, tagging each with calName.
+// Floating times use the local zone.
+func ParseEvents(r io.Reader, calName string) ([]Event, error) {
+	cal, err := ical.NewDecoder(r).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("parsing calendar: %w", err)
+	}
+	return calendarEvents(cal, calName, time.Local), nil
+}
+
+// newHTTPClient builds the client used for syncing, applying the
+// configured redirect policy and TLS settings. Proxies come from the
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+func newHTTPClient(cfg *Config) (*http.Client, error) {
+	max := cfg.MaxRedirects
+	if max <= 0 {
+		max = DefaultMaxRedirects
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	if cfg.CACertFile != "" || cfg.Insecure {
+		tlsConfig := &tls.Config{InsecureSkipVerify: cfg.Insecure}
+		if cfg.CACertFile != "" {
+			pool, err := certPool(cfg.CACertFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConfig.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	timeout := time.Duration(cfg.FetchTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout * time.Second
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if cfg.DisableRedirects {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > max {
+				return fmt.Errorf("stopped after %d redirects", max)
+			}
+			return nil
+		},
+	}, nil
+}
+
+// certPool returns the system roots plus the PEM certificates in path.
+func certPool(path string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading ca_cert_file: %w", err)
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("ca_cert_file %s has no PEM certificates", path)
+	}
+	return pool, nil
+}
+
+// feed is a decoded calendar along with details about how it was fetched.
+type feed struct {
+	Calendar *ical.Calendar
+	// URL is where the response was ultimately served from after redirects.
+	URL string
+	// Skipped counts malformed events dropped by lenient parsing.
+	Skipped int
+}
+
+// fetchCalendar downloads and decodes the feed at url.
+func fetchCalendar(client *http.Client, cfg *Config, url string) (*feed, error) {
+	body, finalURL, err := openFeed(client, cfg, url)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	f := &feed{URL: finalURL}
+	if cfg.Lenient {
+		f.Calendar, f.Skipped, err = decodeLenient(body)
+	} else {
+		f.Calendar, err = ical.NewDecoder(body).Decode()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing calendar: %w", err)
+	}
+	return f, nil
+}
+
+// openFeed returns the decompressed body of the feed at rawURL along with
+// the URL it was ultimately served from. webcal:// is fetched over https
+// and file:// reads from the local filesystem. Gzip-compressed bodies,
+// such as .ics.gz exports, are decompressed. Bodies that don't start with
+// BEGIN:VCALENDAR, such as HTML login pages, are rejected.
+func openFeed(client *http.Client, cfg *Config, rawURL string) (io.ReadCloser, string, error) {
+	u, err := validateSourceURL(rawURL)
+	if err != nil {
+		return nil, "", err
+	}
+	if u.Scheme == "file" {
+		f, err := os.Open(u.Path)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading calendar: %w", err)
+		}
+		r, err := gunzipIfCompressed(f)
+		if err != nil {
+			f.Close()
+			return nil, "", err
+		}
+		body, err := checkICalBody(readCloser{r, f}, "")
+		if err != nil {
+			return nil, "", err
+		}
+		return body, rawURL, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching calendar: %w", err)
+	}
+	req.Header.Set("User-Agent", cfg.userAgent())
+	req.Header.Set("Accept", "text/calendar, */*")
+	// Setting Accept-Encoding ourselves disables the transport's transparent
+	// decompression, so the body is unwrapped below.
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetching calendar: %w", err)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		resp.Body.Close()
+		return nil, "", &RateLimitError{RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, "", fmt.Errorf("fetching calendar: HTTP %d", resp.StatusCode)
+	}
+
+	finalURL := rawURL
+	if resp.Request.URL.String() != u.String() {
+		finalURL = resp.Request.URL.String()
+	}
+	var body io.ReadCloser = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, "", fmt.Errorf("decompressing calendar: %w", err)
+		}
+		body = readCloser{gz, resp.Body}
+	} else {
+		// Some servers send .ics.gz files as is, without Content-Encoding.
+		r, err := gunzipIfCompressed(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, "", err
+		}
+		body = readCloser{r, resp.Body}
+	}
+	body, err = checkICalBody(body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, "", err
+	}
+	return body, finalURL, nil
+}
+
+// checkICalBody peeks at the start of body and fails with a readable error,
+// closing body, when it isn't an iCalendar object. The content type, if
+// known, is included in the error. The returned body starts at BEGIN.
+func checkICalBody(body io.ReadCloser, contentType string) (io.ReadCloser, error) {
+	const begin = "BEGIN:VCALENDAR"
+	br := bufio.NewReader(body)
+	// Peek only as far as needed, so a slow feed can still be read as it
+	// arrives.
+	var head []byte
+	var start string
+	for n := len(begin); ; {
+		var err error
+		head, err = br.Peek(n)
+		start = strings.TrimLeft(strings.TrimPrefix(string(head), "\ufeff"), " \t\r\n")
+		if len(start) >= len(begin) || err != nil || n >= 512 {
+			break
+		}
+		n = min(n+len(begin)-len(start), 512)
+	}
+	if len(start) >= len(begin) && strings.EqualFold(start[:len(begin)], begin) {
+		// Drop a leading BOM or blank lines, which the decoder rejects.
+		br.Discard(len(head) - len(start))
+		return readCloser{br, body}, nil
+	}
+	if more, _ := br.Peek(512); len(more) > len(head) {
+		head = more
+		start = strings.TrimLeft(strings.TrimPrefix(string(head), "\ufeff"), " \t\r\n")
+	}
+	body.Close()
+	got := contentType
+	if got == "" {
+		line, _, _ := strings.Cut(start, "\n")
+		if len(line) > 40 {
+			line = line[:40] + "..."
+		}
+		got = fmt.Sprintf("%q", strings.TrimSpace(line))
+	}
+	return nil, fmt.Errorf("response is not an iCal feed (got %s); check the URL and authentication", got)
+}
+
+// gunzipIfCompressed returns a reader that decompresses r if it starts with
+// the gzip magic bytes, and otherwise reads r as is.
+func gunzipIfCompressed(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) < 2 || magic[0] != 0x1f || magic[1] != 0x8b {
+		return br, nil
+	}
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing calendar: %w", err)
+	}
+	return gz, nil
+}
+
+// feedName returns the X-WR-CALNAME of the iCal stream r, reading no
+// further than its first component.
+func feedName(r io.Reader) string {
+	scanner := bufio.NewScanner(r)
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		upper := strings.ToUpper(line)
+		if strings.HasPrefix(upper, "BEGIN:") && upper != "BEGIN:VCALENDAR" {
+			break
+		}
+		lines = append(lines, line)
+	}
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		name, _, _ = strings.Cut(name, ";")
+		if ok && strings.EqualFold(name, "X-WR-CALNAME") {
+			return strings.TrimSpace(unescapeICalText(value))
+		}
+	}
+	return ""
+}
+
+// unescapeICalText undoes the backslash escapes of an iCal TEXT value.
+func unescapeICalText(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+// readCloser reads from one reader and closes the underlying body.
+type readCloser struct {
+	io.Reader
+	body io.Closer
+}
+
+func (r readCloser) Close() error {
+	return r.body.Close()
+}
+
+// validateSourceURL parses a feed URL and checks that its scheme is one we
+// can sync from. webcal URLs are rewritten to https.
+func validateSourceURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "webcal":
+		u.Scheme = "https"
+	case "file":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid URL %q: file URL has no path", rawURL)
+		}
+		return u, nil
+	case "":
+		return nil, fmt.Errorf("invalid URL %q: missing scheme (use http, https, webcal, or file)", rawURL)
+	default:
+		return nil, fmt.Errorf("invalid URL %q: unsupported scheme %q (use http, https, webcal, or file)", rawURL, u.Scheme)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid URL %q: missing host", rawURL)
+	}
+	return u, nil
+}
+
+// verifySourceURL checks that rawURL is reachable and looks like a calendar,
+// using a HEAD request for remote feeds.
+func verifySourceURL(client *http.Client, cfg *Config, rawURL string) error {
+	u, err := validateSourceURL(rawURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "file" {
+		if _, err := os.Stat(u.Path); err != nil {
+			return fmt.Errorf("verifying %s: %w", rawURL, err)
+		}
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodHead, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("verifying %s: %w", rawURL, err)
+	}
+	req.Header.Set("User-Agent", cfg.userAgent())
+	req.Header.Set("Accept", "text/calendar, */*")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("verifying %s: %w", rawURL, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusMethodNotAllowed {
+		// Some servers don't implement HEAD; let the first sync decide.
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("verifying %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+	ct := strings.ToLower(resp.Header.Get("Content-Type"))
+	if ct != "" && !strings.Contains(ct, "calendar") && !strings.Contains(ct, "ics") {
+		return fmt.Errorf("verifying %s: content type %q is not a calendar (use --no-verify to add anyway)", rawURL, ct)
+	}
+	return nil
+}
+
+// decodeLenient decodes a calendar one VEVENT at a time so a malformed event
+// is dropped instead of failing the whole feed. It returns how many events
+// were skipped.
+func decodeLenient(r io.Reader) (*ical.Calendar, int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var skeleton strings.Builder
+	var blocks []string
+	var block *strings.Builder
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		name := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case block == nil && name == "BEGIN:VEVENT":
+			block = &strings.Builder{}
+			block.WriteString(line)
+		case block != nil:
+			block.WriteString(line)
+			if name == "END:VEVENT" {
+				blocks = append(blocks, block.String())
+				block = nil
+			}
+		default:
+			skeleton.WriteString(line)
+		}
+	}
+	skipped := 0
+	if block != nil {
+		skipped++
+	}
+
+	cal, err := ical.NewDecoder(strings.NewReader(skeleton.String())).Decode()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, b := range blocks {
+		wrapped := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:" + DefaultProductID + "\r\n" +
+			b + "END:VCALENDAR\r\n"
+		eventCal, err := ical.NewDecoder(strings.NewReader(wrapped)).Decode()
+		if err != nil || len(eventCal.Events()) == 0 {
+			skipped++
+			continue
+		}
+		cal.Children = append(cal.Children, eventCal.Events()[0].Component)
+	}
+	return cal, skipped, nil
+}
+
+// calendarEvents parses every VEVENT in cal. Floating times use the
+// calendar's X-WR-TIMEZONE, or loc if it has none.
+func calendarEvents(cal *ical.Calendar, calName string, loc *time.Location) []Event {
+	loc = calendarLocation(cal, loc)
+	var events []Event
+	for _, ie := range cal.Events() {
+		events = append(events, *parseEvent(&ie, calName, loc))
+	}
+	return events
+}
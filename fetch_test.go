@@ -0,0 +1,158 @@
+package calendar
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gzipped compresses s.
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetchGzipFeed(t *testing.T) {
+	body := gzipped(t, testFeed)
+	for _, tc := range []struct {
+		name     string
+		encoding string
+	}{
+		{"content-encoding", "gzip"},
+		{"gzip file without content-encoding", ""},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var accept string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				accept = r.Header.Get("Accept-Encoding")
+				w.Header().Set("Content-Type", "text/calendar")
+				if tc.encoding != "" {
+					w.Header().Set("Content-Encoding", tc.encoding)
+				}
+				w.Write(body)
+			}))
+			defer srv.Close()
+
+			events, err := FetchEvents(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if accept != "gzip" {
+				t.Errorf("Accept-Encoding = %q, want gzip", accept)
+			}
+			if len(events) != 1 || events[0].UID != "one" {
+				t.Errorf("got events %+v, want the one event", events)
+			}
+		})
+	}
+}
+
+func TestFetchRejectsHTML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "feed.ics")
+	page := "\n<html><head><title>Sign in</title></head><body>" + strings.Repeat("x", 1000) + "</body></html>"
+	if err := os.WriteFile(path, []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := FetchEvents("file://" + path)
+	if err == nil || !strings.Contains(err.Error(), `"<html><head><title>Sign in</title></head..."`) {
+		t.Errorf("err = %v, want the start of the page", err)
+	}
+}
+
+func TestSyncRejectsHTMLLoginPage(t *testing.T) {
+	m := newTestManager(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<!DOCTYPE html><html><body><form>Sign in</form></body></html>"))
+	}))
+	defer srv.Close()
+	if err := m.AddSource(Source{Name: "feed", URL: srv.URL}, AddOptions{SkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := m.SyncAll(context.Background(), SyncOptions{})
+	want := "response is not an iCal feed (got text/html; charset=utf-8); check the URL and authentication"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("err = %v, want %q", err, want)
+	}
+}
+
+func TestFetchAcceptsBOMAndBlankLines(t *testing.T) {
+	srv := serveICS(t, "\ufeff\r\n\r\n"+testFeed)
+	events, err := FetchEvents(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Errorf("got %d events, want 1", len(events))
+	}
+}
+
+// rateLimitedFeed answers 429 with retryAfter to the first request and
+// serves testFeed after that.
+func rateLimitedFeed(t *testing.T, retryAfter string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar")
+		w.Write([]byte(testFeed))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestSyncRetriesRateLimitedFeed(t *testing.T) {
+	srv, requests := rateLimitedFeed(t, "1")
+	m := newTestManager(t)
+	if err := m.AddSource(Source{Name: "feed", URL: srv.URL}, AddOptions{SkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := m.SyncSource(context.Background(), "feed", SyncOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Added != 1 || requests.Load() != 2 {
+		t.Errorf("added %d after %d requests, want 1 after 2", res.Added, requests.Load())
+	}
+}
+
+func TestSyncRateLimitWaitCancelled(t *testing.T) {
+	srv, requests := rateLimitedFeed(t, "30")
+	m := newTestManager(t)
+	if err := m.AddSource(Source{Name: "feed", URL: srv.URL}, AddOptions{SkipVerify: true}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err := m.SyncSource(ctx, "feed", SyncOptions{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("sync took %s after ctx was done", elapsed)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests, want 1", n)
+	}
+}
@@ -0,0 +1,149 @@
+package calendar
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+)
+
+// EventFieldNames returns the names of Event's fields as they appear as
+// keys in its JSON, in Event field order. The same names head CSV columns,
+// so every output format agrees.
+func EventFieldNames() []string {
+	t := reflect.TypeOf(Event{})
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).IsExported() {
+			names = append(names, jsonFieldName(t.Field(i)))
+		}
+	}
+	return names
+}
+
+// jsonFieldName returns the JSON key encoding/json uses for f.
+func jsonFieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" {
+		return name
+	}
+	return f.Name
+}
+
+// ParseFields parses a comma-separated field list such as "uid,summary,start",
+// matching names case-insensitively and returning them as EventFieldNames
+// spells them. An empty spec selects every field.
+func ParseFields(spec string) ([]string, error) {
+	valid := EventFieldNames()
+	if strings.TrimSpace(spec) == "" {
+		return valid, nil
+	}
+	var fields []string
+	for _, f := range strings.Split(spec, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		i := slices.IndexFunc(valid, func(v string) bool { return strings.EqualFold(v, f) })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown field %q (valid fields: %s)", f, strings.Join(valid, ", "))
+		}
+		fields = append(fields, valid[i])
+	}
+	return fields, nil
+}
+
+// ProjectedEvent is an event restricted to selected fields. It marshals to
+// a JSON object with the keys in selection order.
+type ProjectedEvent struct {
+	keys   []string
+	values []any
+}
+
+// MarshalJSON implements json.Marshaler.
+func (p ProjectedEvent) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range p.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		val, err := json.Marshal(p.values[i])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(val)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// ProjectEvents restricts events to the given fields, which must come from
+// ParseFields.
+func ProjectEvents(events []Event, fields []string) []ProjectedEvent {
+	projected := make([]ProjectedEvent, len(events))
+	for i, e := range events {
+		v := reflect.ValueOf(e)
+		p := ProjectedEvent{}
+		for _, f := range fields {
+			for j := 0; j < v.NumField(); j++ {
+				field := v.Type().Field(j)
+				if field.IsExported() && jsonFieldName(field) == f {
+					p.keys = append(p.keys, f)
+					p.values = append(p.values, v.Field(j).Interface())
+					break
+				}
+			}
+		}
+		projected[i] = p
+	}
+	return projected
+}
+
+// FormatEventsCSV writes events as CSV with a header row, restricted to the
+// given fields. Times are written in RFC 3339 form.
+func FormatEventsCSV(w io.Writer, events []Event, fields []string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(fields); err != nil {
+		return err
+	}
+	for _, p := range ProjectEvents(events, fields) {
+		record := make([]string, len(p.values))
+		for i, v := range p.values {
+			switch v := v.(type) {
+			case time.Time:
+				if !v.IsZero() {
+					record[i] = v.Format(time.RFC3339)
+				}
+			case []string:
+				record[i] = strings.Join(v, " ")
+			case []Attendee:
+				emails := make([]string, len(v))
+				for j, a := range v {
+					emails[j] = a.Email
+				}
+				record[i] = strings.Join(emails, " ")
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// FormatEventFieldsJSON returns events restricted to fields as JSON,
+// indented unless compact.
+func FormatEventFieldsJSON(events []Event, fields []string, compact bool) (string, error) {
+	return marshalJSON(ProjectEvents(events, fields), compact)
+}
@@ -0,0 +1,58 @@
+package calendar
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFieldNamesMatchAcrossFormats(t *testing.T) {
+	start := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	events := []Event{{UID: "one", Summary: "One", Start: start, End: start.Add(time.Hour), Calendar: "feed"}}
+
+	fields, err := ParseFields("uid, SUMMARY,start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var csv strings.Builder
+	if err := FormatEventsCSV(&csv, events, fields); err != nil {
+		t.Fatal(err)
+	}
+	header, _, _ := strings.Cut(csv.String(), "\n")
+	if header != "UID,Summary,Start" {
+		t.Errorf("CSV header = %q", header)
+	}
+
+	data, err := FormatEventFieldsJSON(events, fields, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"UID":"one","Summary":"One","Start":"2026-01-05T10:00:00Z"}]`; data != want {
+		t.Errorf("projected JSON = %s, want %s", data, want)
+	}
+
+	// Every field name is a key of the full JSON output too, apart from
+	// the omitempty fields this event leaves empty.
+	full, err := FormatEventsJSON(events, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objs []map[string]any
+	if err := json.Unmarshal([]byte(full), &objs); err != nil {
+		t.Fatal(err)
+	}
+	omitted := map[string]bool{"Categories": true, "Attendees": true, "Lat": true, "Lon": true, "Color": true}
+	for _, name := range EventFieldNames() {
+		if _, ok := objs[0][name]; !ok && !omitted[name] {
+			t.Errorf("field %q isn't a JSON key of the event", name)
+		}
+	}
+}
+
+func TestParseFieldsUnknown(t *testing.T) {
+	_, err := ParseFields("uid,nope")
+	if err == nil || !strings.Contains(err.Error(), `unknown field "nope"`) || !strings.Contains(err.Error(), "Summary") {
+		t.Errorf("err = %v, want the unknown field and the valid ones", err)
+	}
+}
@@ -0,0 +1,139 @@
+package calendar
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FilterByLocation returns the events whose location contains substr,
+// ignoring case. Events without a location are dropped.
+func FilterByLocation(events []Event, substr string) []Event {
+	needle := strings.ToLower(substr)
+	var filtered []Event
+	for _, e := range events {
+		if e.Location == "" {
+			continue
+		}
+		if strings.Contains(strings.ToLower(e.Location), needle) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// FilterBySummaryRegexp returns the events whose summary matches re
+// anywhere; anchor the pattern with ^ and $ to match the whole summary.
+func FilterBySummaryRegexp(events []Event, re *regexp.Regexp) []Event {
+	var filtered []Event
+	for _, e := range events {
+		if re.MatchString(e.Summary) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// FilterByCalendar returns the events from the named calendars.
+func FilterByCalendar(events []Event, names []string) []Event {
+	var filtered []Event
+	for _, e := range events {
+		for _, name := range names {
+			if e.Calendar == name {
+				filtered = append(filtered, e)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// FilterByTimeOfDay returns the timed events that start at or after after
+// and before before, both offsets from midnight, on whatever date. Start
+// times are compared as they are displayed, in the event's own zone.
+// All-day events are dropped.
+func FilterByTimeOfDay(events []Event, after, before time.Duration) []Event {
+	var filtered []Event
+	for _, e := range events {
+		if e.AllDay {
+			continue
+		}
+		s := e.Start
+		tod := time.Duration(s.Hour())*time.Hour + time.Duration(s.Minute())*time.Minute + time.Duration(s.Second())*time.Second
+		if tod >= after && tod < before {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// FilterChangedSince returns the events last modified at or after since.
+// Events without a LAST-MODIFIED timestamp are dropped.
+func FilterChangedSince(events []Event, since time.Time) []Event {
+	var filtered []Event
+	for _, e := range events {
+		if e.Modified.IsZero() || e.Modified.Before(since) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+// FilterPastInstances drops recurrence instances, including overrides,
+// that start before now. Other events are kept whenever they start.
+func FilterPastInstances(events []Event, now time.Time) []Event {
+	var filtered []Event
+	for _, e := range events {
+		if !e.RecurrenceID.IsZero() && e.Start.Before(now) {
+			continue
+		}
+		filtered = append(filtered, e)
+	}
+	return filtered
+}
+
+// FilterByRSVP returns the events where the attendee with the given email
+// has the participation status partstat, such as ACCEPTED, ignoring case.
+// Events without any attendees are the user's own and count as accepted;
+// events that invite others but not email are dropped.
+func FilterByRSVP(events []Event, email, partstat string) []Event {
+	var filtered []Event
+	for _, e := range events {
+		status := ""
+		if len(e.Attendees) == 0 {
+			status = "ACCEPTED"
+		}
+		for _, a := range e.Attendees {
+			if strings.EqualFold(a.Email, email) {
+				status = a.PartStat
+				break
+			}
+		}
+		if status != "" && strings.EqualFold(status, partstat) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// LimitPerCalendar keeps the first n events of each calendar by start time,
+// so one busy calendar can't crowd out the rest. The result is sorted by
+// start; n <= 0 keeps every event.
+func LimitPerCalendar(events []Event, n int) []Event {
+	if n <= 0 {
+		return events
+	}
+	sorted := append([]Event(nil), events...)
+	SortEvents(sorted)
+	counts := make(map[string]int)
+	var limited []Event
+	for _, e := range sorted {
+		if counts[e.Calendar] >= n {
+			continue
+		}
+		counts[e.Calendar]++
+		limited = append(limited, e)
+	}
+	return limited
+}
@@ -0,0 +1,156 @@
+package calendar
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Slot is a free interval of time.
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// WorkingHours restricts free-slot search to certain weekdays and a daily
+// window, given as offsets from midnight.
+type WorkingHours struct {
+	Days  []time.Weekday
+	Start time.Duration
+	End   time.Duration
+}
+
+// FormatSlotsJSON returns a slice of slots as JSON, indented unless compact.
+func FormatSlotsJSON(slots []Slot, compact bool) (string, error) {
+	return marshalJSON(slots, compact)
+}
+
+// FreeSlots returns the gaps of at least minLen between from and to that
+// fall within the configured working hours and aren't covered by a timed
+// event padded by buffer on both sides. All-day and cancelled events don't
+// block time.
+func (m *CalendarManager) FreeSlots(from, to time.Time, minLen, buffer time.Duration) ([]Slot, error) {
+	hours, err := m.Config.WorkingHours()
+	if err != nil {
+		return nil, err
+	}
+	events, err := m.overlappingEvents(from, to)
+	if err != nil {
+		return nil, err
+	}
+	return FindFreeSlots(events, from, to, minLen, buffer, hours), nil
+}
+
+// FindFreeSlots computes free slots between from and to given the busy
+// events, each padded by buffer before its start and after its end. Only
+// working days within the working-hours window are searched, so padding
+// never adds time outside it. Cancelled events are ignored.
+func FindFreeSlots(events []Event, from, to time.Time, minLen, buffer time.Duration, hours WorkingHours) []Slot {
+	busy := busyIntervals(events, from.Location(), buffer)
+
+	workday := make(map[time.Weekday]bool, len(hours.Days))
+	for _, d := range hours.Days {
+		workday[d] = true
+	}
+
+	var slots []Slot
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !workday[day.Weekday()] {
+			continue
+		}
+		start := clockOn(day, hours.Start)
+		end := clockOn(day, hours.End)
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		if !start.Before(end) {
+			continue
+		}
+
+		cursor := start
+		for _, b := range busy {
+			if !b.End.After(cursor) || !b.Start.Before(end) {
+				continue
+			}
+			if b.Start.Sub(cursor) >= minLen {
+				slots = append(slots, Slot{Start: cursor, End: b.Start})
+			}
+			if b.End.After(cursor) {
+				cursor = b.End
+			}
+		}
+		if end.Sub(cursor) >= minLen {
+			slots = append(slots, Slot{Start: cursor, End: end})
+		}
+	}
+	return slots
+}
+
+// clockOn returns the wall-clock time offset from midnight on day, in day's
+// location. Unlike day.Add, it stays on the clock on days when DST starts
+// or ends.
+func clockOn(day time.Time, offset time.Duration) time.Time {
+	h, min := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+	return time.Date(day.Year(), day.Month(), day.Day(), h, min, 0, 0, day.Location())
+}
+
+// busyIntervals returns the timed events that aren't cancelled, widened by
+// pad on each side, as sorted, merged intervals in loc.
+func busyIntervals(events []Event, loc *time.Location, pad time.Duration) []Slot {
+	var busy []Slot
+	for _, e := range events {
+		if e.AllDay || e.Status == "CANCELLED" || e.Start.IsZero() || !e.End.After(e.Start) {
+			continue
+		}
+		busy = append(busy, Slot{Start: e.Start.Add(-pad).In(loc), End: e.End.Add(pad).In(loc)})
+	}
+	sort.Slice(busy, func(i, j int) bool { return busy[i].Start.Before(busy[j].Start) })
+
+	var merged []Slot
+	for _, b := range busy {
+		if n := len(merged); n > 0 && !b.Start.After(merged[n-1].End) {
+			if b.End.After(merged[n-1].End) {
+				merged[n-1].End = b.End
+			}
+			continue
+		}
+		merged = append(merged, b)
+	}
+	return merged
+}
+
+// parseClock parses an HH:MM time of day into an offset from midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q (use HH:MM)", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// BusyPeriods returns the merged spans between from and to covered by timed
+// events, in from's location. All-day and cancelled events are left out.
+func (m *CalendarManager) BusyPeriods(from, to time.Time) ([]Slot, error) {
+	events, err := m.overlappingEvents(from, to)
+	if err != nil {
+		return nil, err
+	}
+	var periods []Slot
+	for _, b := range busyIntervals(events, from.Location(), 0) {
+		if !b.End.After(from) {
+			continue
+		}
+		if b.Start.Before(from) {
+			b.Start = from
+		}
+		if b.End.After(to) {
+			b.End = to
+		}
+		periods = append(periods, b)
+	}
+	return periods, nil
+}
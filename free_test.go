@@ -0,0 +1,91 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFindFreeSlotsAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	hours := WorkingHours{
+		Days:  []time.Weekday{time.Sunday},
+		Start: 9 * time.Hour,
+		End:   17 * time.Hour,
+	}
+	// DST starts on 2026-03-08 and ends on 2026-11-01, both Sundays.
+	for _, day := range []time.Time{
+		time.Date(2026, 3, 8, 0, 0, 0, 0, ny),
+		time.Date(2026, 11, 1, 0, 0, 0, 0, ny),
+	} {
+		slots := FindFreeSlots(nil, day, day.AddDate(0, 0, 1), 30*time.Minute, 0, hours)
+		if len(slots) != 1 {
+			t.Fatalf("%s: got %d slots, want 1", day.Format("2006-01-02"), len(slots))
+		}
+		s := slots[0]
+		if s.Start.Hour() != 9 || s.Start.Minute() != 0 || s.End.Hour() != 17 || s.End.Minute() != 0 {
+			t.Errorf("%s: slot %s-%s, want 09:00-17:00", day.Format("2006-01-02"), s.Start.Format("15:04"), s.End.Format("15:04"))
+		}
+	}
+}
+
+func TestLongEventStartedBeforeRange(t *testing.T) {
+	// A three-day offsite that began two days before the range, and a
+	// meeting during it.
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:offsite\r\nDTSTAMP:20260101T000000Z\r\nDTSTART:20260105T080000Z\r\nDTEND:20260108T180000Z\r\nSUMMARY:Offsite\r\nEND:VEVENT\r\n" +
+		"BEGIN:VEVENT\r\nUID:meeting\r\nDTSTAMP:20260101T000000Z\r\nDTSTART:20260107T100000Z\r\nDTEND:20260107T110000Z\r\nSUMMARY:Meeting\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	m := newTestManager(t)
+	syncFeed(t, m, feed)
+	from := time.Date(2026, 1, 7, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+
+	conflicts, err := m.Conflicts(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].First.UID != "offsite" || conflicts[0].Second.UID != "meeting" {
+		t.Errorf("conflicts = %+v, want offsite with meeting", conflicts)
+	}
+
+	busy, err := m.BusyPeriods(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(busy) != 1 || !busy[0].Start.Equal(from) || !busy[0].End.Equal(to) {
+		t.Errorf("busy = %+v, want all of %s", busy, from.Format("2006-01-02"))
+	}
+
+	slots, err := m.FreeSlots(from, to, 30*time.Minute, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 0 {
+		t.Errorf("free slots = %+v, want none during the offsite", slots)
+	}
+}
+
+func TestFindFreeSlotsIgnoresCancelled(t *testing.T) {
+	day := time.Date(2026, 1, 7, 0, 0, 0, 0, time.UTC)
+	hours := WorkingHours{Days: []time.Weekday{day.Weekday()}, Start: 9 * time.Hour, End: 17 * time.Hour}
+	events := []Event{
+		{UID: "meeting", Start: clockOn(day, 10*time.Hour), End: clockOn(day, 11*time.Hour)},
+		{UID: "cancelled", Start: clockOn(day, 14*time.Hour), End: clockOn(day, 15*time.Hour), Status: "CANCELLED"},
+	}
+	slots := FindFreeSlots(events, day, day.AddDate(0, 0, 1), 30*time.Minute, 0, hours)
+	want := []Slot{
+		{Start: clockOn(day, 9*time.Hour), End: clockOn(day, 10*time.Hour)},
+		{Start: clockOn(day, 11*time.Hour), End: clockOn(day, 17*time.Hour)},
+	}
+	if len(slots) != len(want) {
+		t.Fatalf("slots = %+v, want %+v", slots, want)
+	}
+	for i := range want {
+		if !slots[i].Start.Equal(want[i].Start) || !slots[i].End.Equal(want[i].End) {
+			t.Errorf("slot %d = %+v, want %+v", i, slots[i], want[i])
+		}
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/charmbracelet/huh v0.8.0
 	github.com/emersion/go-ical v0.0.0-20250609112844-439c63cef608
 	github.com/spf13/cobra v1.10.2
+	golang.org/x/sys v0.33.0
 )
 
 require (
@@ -36,6 +37,5 @@ require (
 	github.com/teambition/rrule-go v1.8.2 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
 	golang.org/x/sync v0.15.0 // indirect
-	golang.org/x/sys v0.33.0 // indirect
 	golang.org/x/text v0.23.0 // indirect
 )
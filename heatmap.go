@@ -0,0 +1,91 @@
+package calendar
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// heatmapShades are the cells for days with events, from fewest to most.
+var heatmapShades = []rune{'░', '▒', '▓', '█'}
+
+// DailyCounts counts events by the day they start in loc. Keys are
+// midnight in loc.
+func DailyCounts(events []Event, loc *time.Location) map[time.Time]int {
+	counts := make(map[time.Time]int)
+	for _, e := range events {
+		s := e.Start.In(loc)
+		if e.AllDay {
+			// All-day starts are already midnight in their own zone.
+			s = e.Start
+		}
+		counts[time.Date(s.Year(), s.Month(), s.Day(), 0, 0, 0, 0, loc)]++
+	}
+	return counts
+}
+
+// FormatHeatmap renders counts as a contributions-style grid for year: one
+// column per week and one row per weekday, with darker cells for busier
+// days relative to the busiest one. Only the date of each key is used.
+func FormatHeatmap(counts map[time.Time]int, year int) string {
+	byDay := make(map[string]int, len(counts))
+	for day, n := range counts {
+		if day.Year() == year {
+			byDay[day.Format("2006-01-02")] += n
+		}
+	}
+	max, total := 0, 0
+	for _, n := range byDay {
+		total += n
+		if n > max {
+			max = n
+		}
+	}
+
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC)
+	// Columns start on Sunday, so the first one may begin in December.
+	gridStart := first.AddDate(0, 0, -int(first.Weekday()))
+	weeks := int(last.Sub(gridStart).Hours()/24)/7 + 1
+
+	const labelWidth = 4
+	header := []rune(strings.Repeat(" ", weeks))
+	nextFree := 0
+	for m := time.January; m <= time.December; m++ {
+		col := int(time.Date(year, m, 1, 0, 0, 0, 0, time.UTC).Sub(gridStart).Hours()/24) / 7
+		name := m.String()[:3]
+		if col < nextFree || col+len(name) > weeks {
+			continue
+		}
+		copy(header[col:], []rune(name))
+		nextFree = col + len(name) + 1
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%*s%s\n", labelWidth, "", strings.TrimRight(string(header), " "))
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		label := ""
+		if wd == time.Monday || wd == time.Wednesday || wd == time.Friday {
+			label = wd.String()[:3]
+		}
+		row := make([]rune, weeks)
+		for w := range row {
+			day := gridStart.AddDate(0, 0, w*7+int(wd))
+			switch n := byDay[day.Format("2006-01-02")]; {
+			case day.Year() != year:
+				row[w] = ' '
+			case n == 0:
+				row[w] = '·'
+			default:
+				row[w] = heatmapShades[(n*len(heatmapShades)+max-1)/max-1]
+			}
+		}
+		fmt.Fprintf(&b, "%-*s%s\n", labelWidth, label, strings.TrimRight(string(row), " "))
+	}
+	fmt.Fprintf(&b, "\n%*sless · ░ ▒ ▓ █ more    %d events in %d", labelWidth, "", total, year)
+	if max > 0 {
+		fmt.Fprintf(&b, ", busiest day %d", max)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
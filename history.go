@@ -0,0 +1,97 @@
+package calendar
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// SyncRecord is one line of sync.log: a SyncAll run and what it did to each
+// calendar it tried to sync.
+type SyncRecord struct {
+	Time    time.Time          `json:"time"`
+	Sources []SyncSourceRecord `json:"sources"`
+}
+
+// SyncSourceRecord is one calendar's outcome in a SyncRecord. Error is set,
+// and the counts are zero, when the calendar failed or was skipped because
+// its feed was rate limited. It is also set beside the counts when only
+// some of a calendar's feeds failed.
+type SyncSourceRecord struct {
+	Calendar  string `json:"calendar"`
+	Added     int    `json:"added"`
+	Updated   int    `json:"updated"`
+	Removed   int    `json:"removed"`
+	Unchanged int    `json:"unchanged"`
+	Skipped   int    `json:"skipped,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// appendSyncLog adds r to sync.log, first moving a log over
+// Config.MaxSyncLogKB aside to sync.log.1, replacing any older one.
+func (m *CalendarManager) appendSyncLog(r SyncRecord) error {
+	path := m.Config.SyncLogFile()
+	if info, err := os.Stat(path); err == nil && info.Size() > int64(m.Config.MaxSyncLogKB)*1024 {
+		if err := os.Rename(path, path+".1"); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// SyncHistory returns the last n records of sync.log, oldest first,
+// including those rotated to sync.log.1. All records are returned when n
+// is zero. Lines that can't be parsed, such as one cut short by a crash,
+// are skipped.
+func (m *CalendarManager) SyncHistory(n int) ([]SyncRecord, error) {
+	path := m.Config.SyncLogFile()
+	var records []SyncRecord
+	for _, p := range []string{path + ".1", path} {
+		f, err := os.Open(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		scanner := bufio.NewScanner(f)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			var r SyncRecord
+			if err := json.Unmarshal(scanner.Bytes(), &r); err == nil {
+				records = append(records, r)
+			}
+		}
+		err = scanner.Err()
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if n > 0 && len(records) > n {
+		records = records[len(records)-n:]
+	}
+	return records, nil
+}
+
+// FormatSyncHistoryJSON returns sync records as JSON, indented unless
+// compact.
+func FormatSyncHistoryJSON(records []SyncRecord, compact bool) (string, error) {
+	if records == nil {
+		records = []SyncRecord{}
+	}
+	return marshalJSON(records, compact)
+}
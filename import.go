@@ -0,0 +1,215 @@
+package calendar
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// Actions reported by ImportICS.
+const (
+	ImportAdded     = "added"
+	ImportUpdated   = "updated"
+	ImportUnchanged = "unchanged"
+	ImportCancelled = "cancelled"
+	ImportNotFound  = "not found"
+)
+
+// ImportResult is the action ImportICS took for one UID. RecurrenceID is set
+// when a cancellation applied to a single instance.
+type ImportResult struct {
+	UID          string `json:"uid"`
+	RecurrenceID string `json:"recurrence_id,omitempty"`
+	Action       string `json:"action"`
+}
+
+// ImportICS reads an iCalendar object, such as an emailed invite, into the
+// local calendar calName, creating the calendar if needed. The VCALENDAR
+// METHOD decides what happens: PUBLISH and REQUEST (or no METHOD) add or
+// update events, keeping whichever copy has the higher SEQUENCE, and CANCEL
+// removes the matching events, or adds an EXDATE when a single instance is
+// cancelled. Gzip-compressed input is decompressed first.
+func (m *CalendarManager) ImportICS(calName string, r io.Reader) ([]ImportResult, error) {
+	r, err := gunzipIfCompressed(r)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := ical.NewDecoder(r).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("parsing calendar: %w", err)
+	}
+	return m.importCalendar(calName, cal)
+}
+
+// importCalendar imports a decoded calendar as ImportICS describes.
+func (m *CalendarManager) importCalendar(calName string, cal *ical.Calendar) ([]ImportResult, error) {
+	// Check METHOD before creating the calendar, so a rejected file
+	// leaves nothing behind.
+	method, _ := cal.Props.Text(ical.PropMethod)
+	method = strings.ToUpper(method)
+	switch method {
+	case "", "PUBLISH", "REQUEST", "CANCEL":
+	default:
+		return nil, fmt.Errorf("unsupported METHOD %s (only PUBLISH, REQUEST and CANCEL can be imported)", method)
+	}
+	calName, err := m.ensureLocalCalendar(calName)
+	if err != nil {
+		return nil, err
+	}
+	if method == "CANCEL" {
+		return m.cancelEvents(calName, cal)
+	}
+	return m.importEvents(calName, cal)
+}
+
+// importEvents merges the events in cal with any stored copies in calName.
+func (m *CalendarManager) importEvents(calName string, cal *ical.Calendar) ([]ImportResult, error) {
+	uids := eventUIDs(cal)
+	if len(uids) == 0 {
+		return nil, fmt.Errorf("no events to import")
+	}
+	stored, err := m.storedEvents(calName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Stored copies go first so an imported component with the same
+	// RECURRENCE-ID and SEQUENCE replaces them in splitEvents.
+	merged := ical.NewCalendar()
+	for name, props := range cal.Props {
+		if name != ical.PropMethod {
+			merged.Props[name] = props
+		}
+	}
+	for _, uid := range uids {
+		if data, ok := stored[uid]; ok {
+			old, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+			if err != nil {
+				return nil, fmt.Errorf("reading stored event %q: %w", uid, err)
+			}
+			merged.Children = append(merged.Children, old.Children...)
+		}
+	}
+	merged.Children = append(merged.Children, cal.Children...)
+
+	files := splitEvents(merged, m.Config.ProductID, m.Config.EventFormat == EventFormatRaw)
+	var results []ImportResult
+	for _, uid := range uids {
+		data, ok := files[uid]
+		if !ok {
+			return results, fmt.Errorf("encoding event %q failed", uid)
+		}
+		res := ImportResult{UID: uid, Action: ImportAdded}
+		if old, ok := stored[uid]; ok {
+			res.Action = ImportUpdated
+			if bytes.Equal(old, data) {
+				res.Action = ImportUnchanged
+			}
+		}
+		if res.Action != ImportUnchanged {
+			if err := m.Store.SaveEvent(calName, uid, data); err != nil {
+				return results, err
+			}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// cancelEvents deletes the events cancelled by cal from calName.
+func (m *CalendarManager) cancelEvents(calName string, cal *ical.Calendar) ([]ImportResult, error) {
+	stored, err := m.storedEvents(calName)
+	if err != nil {
+		return nil, err
+	}
+	var results []ImportResult
+	for _, event := range cal.Events() {
+		uid, err := event.Props.Text(ical.PropUID)
+		if err != nil || uid == "" {
+			continue
+		}
+		res := ImportResult{UID: uid, Action: ImportCancelled}
+		rid := event.Props.Get(ical.PropRecurrenceID)
+		if rid != nil {
+			res.RecurrenceID = rid.Value
+		}
+		data, ok := stored[uid]
+		switch {
+		case !ok:
+			res.Action = ImportNotFound
+		case rid == nil:
+			if err := m.Store.DeleteEvent(calName, uid); err != nil {
+				return results, err
+			}
+			delete(stored, uid)
+		default:
+			data, err = cancelInstance(data, rid)
+			if err != nil {
+				return results, fmt.Errorf("cancelling %q: %w", uid, err)
+			}
+			if err := m.Store.SaveEvent(calName, uid, data); err != nil {
+				return results, err
+			}
+			stored[uid] = data
+		}
+		results = append(results, res)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("no events to cancel")
+	}
+	return results, nil
+}
+
+// cancelInstance adds rid as an EXDATE of the master event in data and drops
+// any override stored for that instance.
+func cancelInstance(data []byte, rid *ical.Prop) ([]byte, error) {
+	cal, err := ical.NewDecoder(bytes.NewReader(data)).Decode()
+	if err != nil {
+		return nil, err
+	}
+	var children []*ical.Component
+	var master *ical.Component
+	for _, child := range cal.Children {
+		if child.Name == ical.CompEvent {
+			p := child.Props.Get(ical.PropRecurrenceID)
+			if p != nil && p.Value == rid.Value {
+				continue
+			}
+			if p == nil {
+				master = child
+			}
+		}
+		children = append(children, child)
+	}
+	cal.Children = children
+	if master != nil {
+		exdate := ical.NewProp(ical.PropExceptionDates)
+		exdate.Value = rid.Value
+		exdate.Params = rid.Params
+		master.Props.Add(exdate)
+	}
+
+	var buf bytes.Buffer
+	if err := ical.NewEncoder(&buf).Encode(cal); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// eventUIDs returns the distinct UIDs of cal's events in file order.
+func eventUIDs(cal *ical.Calendar) []string {
+	var uids []string
+	seen := make(map[string]bool)
+	for _, event := range cal.Events() {
+		uid, err := event.Props.Text(ical.PropUID)
+		if err != nil || uid == "" || seen[uid] {
+			continue
+		}
+		seen[uid] = true
+		uids = append(uids, uid)
+	}
+	return uids
+}
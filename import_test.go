@@ -0,0 +1,104 @@
+package calendar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestImportRejectsMethodBeforeCreatingCalendar(t *testing.T) {
+	m := newTestManager(t)
+	invite := strings.Replace(testFeed, "PRODID:-//test//EN\r\n", "PRODID:-//test//EN\r\nMETHOD:COUNTER\r\n", 1)
+	_, err := m.ImportICS("invites", strings.NewReader(invite))
+	if err == nil || !strings.Contains(err.Error(), "unsupported METHOD COUNTER") {
+		t.Fatalf("err = %v, want unsupported METHOD", err)
+	}
+	sources, err := m.LoadSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sources) != 0 {
+		t.Errorf("rejected import created calendars %+v", sources)
+	}
+
+	results, err := m.ImportICS("invites", strings.NewReader(strings.Replace(invite, "METHOD:COUNTER", "METHOD:request", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].UID != "one" {
+		t.Errorf("results = %+v, want the event imported", results)
+	}
+}
+
+func TestImportAndSyncGzipFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.ics.gz")
+	if err := os.WriteFile(path, gzipped(t, testFeed), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := newTestManager(t)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	results, err := m.ImportICS("imported", f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].UID != "one" || results[0].Action != "added" {
+		t.Errorf("import results = %+v, want one added", results)
+	}
+
+	m = newTestManager(t)
+	if err := m.AddSource(Source{Name: "feed", URL: "file://" + path}, AddOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.SyncAll(context.Background(), SyncOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if e, _, err := m.GetEvent("one"); err != nil || e.Summary != "One" {
+		t.Errorf("synced event = %+v, %v", e, err)
+	}
+}
+
+func TestImportDirIncludesGzipFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"a.ics":       []byte(testFeed),
+		"b.ICS.gz":    gzipped(t, testFeed),
+		"notes.txt":   []byte("not a calendar"),
+		"sub/c.ics":   []byte(testFeed),
+		"archive.zip": gzipped(t, testFeed),
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := newTestManager(t)
+	results, err := m.ImportDir(context.Background(), "imported", dir, ImportDirOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Path, r.Err)
+		}
+		paths = append(paths, filepath.Base(r.Path))
+	}
+	if got := strings.Join(paths, ","); got != "a.ics,b.ICS.gz" {
+		t.Errorf("imported %s, want a.ics,b.ICS.gz", got)
+	}
+	if len(results) == 2 && !slices.Equal(results[1].Duplicates, []string{"one"}) {
+		t.Errorf("b.ICS.gz duplicates = %v, want [one]", results[1].Duplicates)
+	}
+}
@@ -0,0 +1,191 @@
+package calendar
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// ImportDirOptions controls ImportDir.
+type ImportDirOptions struct {
+	// Recursive also descends into subdirectories.
+	Recursive bool
+	// Workers is how many files are imported at once, runtime.NumCPU()
+	// when zero.
+	Workers int
+	// Progress, if set, is called after each file with the number of files
+	// done so far and the total. Calls are never concurrent.
+	Progress func(done, total int)
+}
+
+// FileImportResult is the outcome of importing one file in ImportDir.
+// Duplicates lists the UIDs that an earlier file, in path order, also
+// had; ImportICS merges them as usual, keeping the higher SEQUENCE.
+type FileImportResult struct {
+	Path       string         `json:"path"`
+	Results    []ImportResult `json:"results,omitempty"`
+	Duplicates []string       `json:"duplicates,omitempty"`
+	Err        error          `json:"-"`
+}
+
+// ImportDir imports every .ics or .ics.gz file in dir into the local calendar calName
+// with ImportICS, several files at a time. Files that share a UID are never
+// imported at the same time, so each UID ends up merged from all of them.
+// Cancelling ctx stops files that haven't started; those are reported with
+// ctx's error. The results are in path order, one per file; a failed file
+// doesn't stop the others.
+func (m *CalendarManager) ImportDir(ctx context.Context, calName, dir string, opts ImportDirOptions) ([]FileImportResult, error) {
+	paths, err := icsFiles(dir, opts.Recursive)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no .ics or .ics.gz files in %s", dir)
+	}
+	// Create the calendar once up front rather than racing to in every
+	// worker.
+	if calName, err = m.ensureLocalCalendar(calName); err != nil {
+		return nil, err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	results := make([]FileImportResult, len(paths))
+	locks := newUIDLocks()
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	done := 0
+	for range min(workers, len(paths)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				res := &results[i]
+				res.Path = paths[i]
+				if err := ctx.Err(); err != nil {
+					res.Err = err
+				} else {
+					res.Results, res.Err = m.importFile(calName, paths[i], locks)
+				}
+				mu.Lock()
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done, len(paths))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for i := range paths {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Duplicates are worked out afterwards so they don't depend on which
+	// worker got to a UID first.
+	seen := make(map[string]bool)
+	for i := range results {
+		for _, r := range results[i].Results {
+			if seen[r.UID] && !slices.Contains(results[i].Duplicates, r.UID) {
+				results[i].Duplicates = append(results[i].Duplicates, r.UID)
+			}
+		}
+		for _, r := range results[i].Results {
+			seen[r.UID] = true
+		}
+	}
+	return results, nil
+}
+
+// importFile imports one file as ImportICS would, holding the locks of its
+// UIDs so no other file with the same events is imported meanwhile.
+func (m *CalendarManager) importFile(calName, path string, locks *uidLocks) ([]ImportResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := gunzipIfCompressed(f)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := ical.NewDecoder(r).Decode()
+	if err != nil {
+		return nil, fmt.Errorf("parsing calendar: %w", err)
+	}
+	unlock := locks.lock(eventUIDs(cal))
+	defer unlock()
+	return m.importCalendar(calName, cal)
+}
+
+// icsFiles returns the .ics and .ics.gz files in dir, sorted, descending into
+// subdirectories only when recursive is set.
+func icsFiles(dir string, recursive bool) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := strings.ToLower(d.Name())
+		if strings.HasSuffix(name, ".ics") || strings.HasSuffix(name, ".ics.gz") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
+
+// uidLocks hands out a mutex per UID.
+type uidLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+func newUIDLocks() *uidLocks {
+	return &uidLocks{locks: make(map[string]*sync.Mutex)}
+}
+
+// lock locks every UID in uids, in sorted order so two callers can't
+// deadlock, and returns a function that unlocks them.
+func (l *uidLocks) lock(uids []string) func() {
+	uids = slices.Clone(uids)
+	sort.Strings(uids)
+	uids = slices.Compact(uids)
+	held := make([]*sync.Mutex, len(uids))
+	for i, uid := range uids {
+		l.mu.Lock()
+		mu, ok := l.locks[uid]
+		if !ok {
+			mu = new(sync.Mutex)
+			l.locks[uid] = mu
+		}
+		l.mu.Unlock()
+		mu.Lock()
+		held[i] = mu
+	}
+	return func() {
+		for _, mu := range held {
+			mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrSyncInProgress is returned when another process holds the sync lock.
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+// errLocked is returned by lockFile when another open file holds the lock.
+var errLocked = errors.New("file is locked")
+
+// acquireLock takes an exclusive advisory lock on path, creating it and
+// recording our PID, and returns a function that releases it. The lock is
+// held on the open file, so the operating system drops it when a process
+// exits, even one that crashed; a leftover file doesn't block anyone.
+func acquireLock(path string) (func(), error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+		if err != nil {
+			return nil, err
+		}
+		if err := lockFile(f); err != nil {
+			f.Close()
+			if errors.Is(err, errLocked) {
+				return nil, fmt.Errorf("%w (%s is held by another process)", ErrSyncInProgress, path)
+			}
+			return nil, err
+		}
+		// The holder we waited on may have removed the file as it let go,
+		// leaving us locking a file nobody else will open. Start over on
+		// whatever is at path now.
+		if !samePath(f, path) {
+			unlockFile(f)
+			f.Close()
+			continue
+		}
+		f.Truncate(0)
+		fmt.Fprintf(f, "%d\n", os.Getpid())
+		return func() {
+			// Remove before unlocking so the next process can't lock the
+			// file we're about to remove; samePath catches one that opened
+			// it before the removal.
+			os.Remove(path)
+			unlockFile(f)
+			f.Close()
+		}, nil
+	}
+}
+
+// samePath reports whether path still names the open file f.
+func samePath(f *os.File, path string) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	pi, err := os.Stat(path)
+	return err == nil && os.SameFile(fi, pi)
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package calendar
+
+import "os"
+
+// lockFile does nothing on systems without flock or LockFileEx, so syncs
+// there aren't kept from overlapping.
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
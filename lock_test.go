@@ -0,0 +1,76 @@
+package calendar
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestAcquireLockHeld(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	unlock, err := acquireLock(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if _, err := acquireLock(path); !errors.Is(err, ErrSyncInProgress) {
+		t.Fatalf("second acquireLock = %v, want ErrSyncInProgress", err)
+	}
+}
+
+func TestAcquireLockLeftover(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	// A lock file left by a process that crashed, which no one holds.
+	if err := os.WriteFile(path, []byte("2147483600\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := acquireLock(path)
+	if err != nil {
+		t.Fatalf("acquireLock over a stale lock: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != strconv.Itoa(os.Getpid())+"\n" {
+		t.Errorf("lock holds %q, want our PID", data)
+	}
+	unlock()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("lock not removed after unlock")
+	}
+}
+
+func TestAcquireLockExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sync.lock")
+	var held, overlaps atomic.Int32
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				unlock, err := acquireLock(path)
+				if errors.Is(err, ErrSyncInProgress) {
+					continue
+				}
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if held.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				time.Sleep(time.Millisecond)
+				held.Add(-1)
+				unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if n := overlaps.Load(); n > 0 {
+		t.Errorf("lock held by two callers at once %d times", n)
+	}
+}
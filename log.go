@@ -0,0 +1,52 @@
+package calendar
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// LogLevel controls how much progress output the manager prints.
+type LogLevel int
+
+const (
+	// LogQuiet prints only errors.
+	LogQuiet LogLevel = iota
+	// LogNormal prints progress lines and errors.
+	LogNormal
+	// LogVerbose adds per-event and HTTP timing detail.
+	LogVerbose
+)
+
+// Logger writes leveled progress output to Out and errors to Err, so errors
+// don't end up mixed into output like -o json.
+type Logger struct {
+	Out   io.Writer
+	Err   io.Writer
+	Level LogLevel
+}
+
+// NewLogger returns a Logger writing progress to stdout and errors to
+// stderr at LogNormal.
+func NewLogger() *Logger {
+	return &Logger{Out: os.Stdout, Err: os.Stderr, Level: LogNormal}
+}
+
+// Errorf prints a message to Err at every level.
+func (l *Logger) Errorf(format string, args ...any) {
+	fmt.Fprintf(l.Err, format, args...)
+}
+
+// Infof prints a message unless the logger is quiet.
+func (l *Logger) Infof(format string, args ...any) {
+	if l.Level >= LogNormal {
+		fmt.Fprintf(l.Out, format, args...)
+	}
+}
+
+// Debugf prints a message only when the logger is verbose.
+func (l *Logger) Debugf(format string, args ...any) {
+	if l.Level >= LogVerbose {
+		fmt.Fprintf(l.Out, format, args...)
+	}
+}
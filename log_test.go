@@ -0,0 +1,28 @@
+package calendar
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLoggerSeparatesErrors(t *testing.T) {
+	var out, errOut bytes.Buffer
+	l := &Logger{Out: &out, Err: &errOut, Level: LogQuiet}
+	l.Infof("progress\n")
+	l.Errorf("failed\n")
+	if out.String() != "" {
+		t.Errorf("quiet Out = %q, want nothing", out.String())
+	}
+	if errOut.String() != "failed\n" {
+		t.Errorf("Err = %q, want the error", errOut.String())
+	}
+
+	out.Reset()
+	errOut.Reset()
+	l.Level = LogNormal
+	l.Infof("progress\n")
+	l.Debugf("detail\n")
+	if out.String() != "progress\n" || errOut.String() != "" {
+		t.Errorf("Out = %q, Err = %q, want only progress on Out", out.String(), errOut.String())
+	}
+}
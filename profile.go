@@ -0,0 +1,164 @@
+package calendar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile is the profile whose data lives directly in the base
+// directory, as it did before profiles existed.
+const DefaultProfile = "default"
+
+// activeProfileFile records the profile chosen with UseProfile.
+const activeProfileFile = "profile"
+
+// DefaultBaseDir returns the CALENDAR_DIR environment variable or
+// ~/.config/calendar.
+func DefaultBaseDir() (string, error) {
+	if dir := os.Getenv("CALENDAR_DIR"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "calendar"), nil
+}
+
+// ActiveProfile returns the profile to use under base: CALENDAR_PROFILE if
+// set, else the one saved by UseProfile, else DefaultProfile.
+func ActiveProfile(base string) (string, error) {
+	if p := os.Getenv("CALENDAR_PROFILE"); p != "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(filepath.Join(base, activeProfileFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DefaultProfile, nil
+		}
+		return "", err
+	}
+	if p := strings.TrimSpace(string(data)); p != "" {
+		return p, nil
+	}
+	return DefaultProfile, nil
+}
+
+// ProfileDir returns the directory holding profile's sources.json, events
+// and config.json. The default profile uses base itself.
+func ProfileDir(base, profile string) string {
+	if profile == "" || profile == DefaultProfile {
+		return base
+	}
+	return filepath.Join(base, profile)
+}
+
+// LoadProfileConfig creates a Config for profile under base. Profiles other
+// than the default must have been created with CreateProfile.
+func LoadProfileConfig(base, profile string) (*Config, error) {
+	if err := validateProfileName(profile); err != nil {
+		return nil, err
+	}
+	dir := ProfileDir(base, profile)
+	if profile != DefaultProfile {
+		if _, err := os.Stat(dir); err != nil {
+			if os.IsNotExist(err) {
+				return nil, fmt.Errorf("profile %q not found (create it with: calendar profile create %s)", profile, profile)
+			}
+			return nil, err
+		}
+	}
+	cfg, err := LoadConfig(dir)
+	if err != nil {
+		return nil, err
+	}
+	cfg.Profile = profile
+	return cfg, nil
+}
+
+// ListProfiles returns the default profile followed by every profile
+// created under base, sorted by name.
+func ListProfiles(base string) ([]string, error) {
+	entries, err := os.ReadDir(base)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && validateProfileName(e.Name()) == nil && e.Name() != DefaultProfile {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...), nil
+}
+
+// CreateProfile creates an empty profile directory under base.
+func CreateProfile(base, profile string) error {
+	if err := validateProfileName(profile); err != nil {
+		return err
+	}
+	if profile == DefaultProfile {
+		return fmt.Errorf("profile %q already exists", profile)
+	}
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return err
+	}
+	if err := os.Mkdir(ProfileDir(base, profile), 0755); err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("profile %q already exists", profile)
+		}
+		return err
+	}
+	return nil
+}
+
+// UseProfile makes profile the active one under base when CALENDAR_PROFILE
+// and --profile are not given.
+func UseProfile(base, profile string) error {
+	if _, err := LoadProfileConfig(base, profile); err != nil {
+		return err
+	}
+	path := filepath.Join(base, activeProfileFile)
+	if profile == DefaultProfile {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(base, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, []byte(profile+"\n"), 0644)
+}
+
+// reservedProfileNames are the files and directories the default profile
+// keeps in the base directory, which a profile directory would collide
+// with. Rotated and backup copies, such as sync.log.1, share their prefix.
+var reservedProfileNames = []string{
+	activeProfileFile, "events", "config.json", "sources.json", "queries.json", "sync.lock", "sync.log",
+}
+
+// validateProfileName rejects names that can't be a directory under the
+// base or would collide with the default profile's own files. Names are
+// compared ignoring case, as some filesystems do.
+func validateProfileName(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("profile name is required")
+	case strings.HasPrefix(name, "."):
+		return fmt.Errorf("invalid profile name %q", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("invalid profile name %q: must not contain path separators", name)
+	}
+	lower := strings.ToLower(name)
+	for _, r := range reservedProfileNames {
+		if lower == r || strings.HasPrefix(lower, r+".") {
+			return fmt.Errorf("invalid profile name %q: %s is used by the default profile", name, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+package calendar
+
+import "testing"
+
+func TestValidateProfileName(t *testing.T) {
+	for _, name := range []string{
+		"", ".hidden", "..", "a/b", `a\b`,
+		"profile", "events", "sources.json", "config.json", "queries.json", "sync.lock", "sync.log",
+		"sync.log.1", "sources.json.20260101T000000.000000000.bak", "Sources.JSON", "EVENTS",
+	} {
+		if err := validateProfileName(name); err == nil {
+			t.Errorf("validateProfileName(%q) accepted a reserved or invalid name", name)
+		}
+	}
+	for _, name := range []string{"work", "home-2", "profiles", "sync", "events2"} {
+		if err := validateProfileName(name); err != nil {
+			t.Errorf("validateProfileName(%q) = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package calendar
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SavedQuery is a named set of event filters, as given to the events
+// command, kept in queries.json so they can be run again by name.
+type SavedQuery struct {
+	// Range is the positional range, such as ["week"] or two dates. From
+	// and To replace it when set.
+	Range []string `json:"range,omitempty"`
+	From  string   `json:"from,omitempty"`
+	To    string   `json:"to,omitempty"`
+
+	// Calendars keeps only events from these calendars.
+	Calendars []string `json:"calendars,omitempty"`
+	// Location keeps events whose location contains it, ignoring case.
+	Location string `json:"location,omitempty"`
+	// SummaryRegex keeps events whose summary matches this Go regexp.
+	SummaryRegex string `json:"summary_regex,omitempty"`
+	// After and Before keep timed events starting at or after, and
+	// before, an HH:MM time of day.
+	After  string `json:"after,omitempty"`
+	Before string `json:"before,omitempty"`
+	// ChangedSince keeps events modified on or after a YYYY-MM-DD date,
+	// from its midnight in the configured zone.
+	ChangedSince string `json:"changed_since,omitempty"`
+	// RSVP keeps events MyEmail (or Config.Email) responded to this way.
+	RSVP    string `json:"rsvp,omitempty"`
+	MyEmail string `json:"my_email,omitempty"`
+	// FutureOnly drops recurrence instances that have already started,
+	// even inside the range.
+	FutureOnly bool `json:"future_only,omitempty"`
+	// LimitPerCalendar keeps at most this many events per calendar.
+	LimitPerCalendar int `json:"limit_per_calendar,omitempty"`
+	// Sort is "start" (the default) or "priority".
+	Sort string `json:"sort,omitempty"`
+	// Near, a "lat,lon" pair, orders events by distance from it instead,
+	// with events lacking GEO last.
+	Near string `json:"near,omitempty"`
+}
+
+// Filter applies the query's filters and sort order to events, which should
+// already be limited to its range and expanded. FutureOnly is measured
+// from now.
+func (q SavedQuery) Filter(events []Event, cfg *Config, now time.Time) ([]Event, error) {
+	if len(q.Calendars) > 0 {
+		events = FilterByCalendar(events, q.Calendars)
+	}
+	if q.Location != "" {
+		events = FilterByLocation(events, q.Location)
+	}
+	if q.SummaryRegex != "" {
+		re, err := regexp.Compile(q.SummaryRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --summary-regex: %w", err)
+		}
+		events = FilterBySummaryRegexp(events, re)
+	}
+	if q.After != "" || q.Before != "" {
+		after, before := time.Duration(0), 24*time.Hour
+		var err error
+		if q.After != "" {
+			if after, err = parseClock(q.After); err != nil {
+				return nil, fmt.Errorf("invalid --after: %w", err)
+			}
+		}
+		if q.Before != "" {
+			if before, err = parseClock(q.Before); err != nil {
+				return nil, fmt.Errorf("invalid --before: %w", err)
+			}
+		}
+		if before <= after {
+			return nil, fmt.Errorf("--before %s must be later than --after %s", q.Before, q.After)
+		}
+		events = FilterByTimeOfDay(events, after, before)
+	}
+	if q.ChangedSince != "" {
+		since, err := time.ParseInLocation("2006-01-02", q.ChangedSince, cfg.Location())
+		if err != nil {
+			return nil, fmt.Errorf("invalid --changed-since date %q (use YYYY-MM-DD)", q.ChangedSince)
+		}
+		events = FilterChangedSince(events, since)
+	}
+	if q.RSVP != "" {
+		rsvp := strings.ToLower(q.RSVP)
+		switch rsvp {
+		case "accepted", "tentative", "declined", "needs-action":
+		default:
+			return nil, fmt.Errorf("invalid --rsvp %q (use accepted, tentative, declined or needs-action)", q.RSVP)
+		}
+		email := q.MyEmail
+		if email == "" {
+			email = cfg.Email
+		}
+		if email == "" {
+			return nil, fmt.Errorf("--rsvp needs your address: pass --my-email or set email in config.json")
+		}
+		events = FilterByRSVP(events, email, rsvp)
+	}
+	if q.FutureOnly {
+		events = FilterPastInstances(events, now)
+	}
+	if q.LimitPerCalendar > 0 {
+		events = LimitPerCalendar(events, q.LimitPerCalendar)
+	}
+	switch q.Sort {
+	case "", "start":
+	case "priority":
+		SortEventsByPriority(events)
+	default:
+		return nil, fmt.Errorf("invalid --sort %q (use start or priority)", q.Sort)
+	}
+	if q.Near != "" {
+		lat, lon, err := ParseLatLon(q.Near)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --near: %w", err)
+		}
+		SortEventsByDistance(events, lat, lon)
+	}
+	return events, nil
+}
+
+// String returns the query as the events arguments and flags that make it
+// up, such as "week --calendar Work --before 12:00".
+func (q SavedQuery) String() string {
+	args := append([]string(nil), q.Range...)
+	flag := func(name, value string) {
+		if value != "" {
+			args = append(args, "--"+name, value)
+		}
+	}
+	flag("from", q.From)
+	flag("to", q.To)
+	for _, c := range q.Calendars {
+		flag("calendar", c)
+	}
+	flag("location", q.Location)
+	flag("summary-regex", q.SummaryRegex)
+	flag("after", q.After)
+	flag("before", q.Before)
+	flag("changed-since", q.ChangedSince)
+	flag("rsvp", q.RSVP)
+	flag("my-email", q.MyEmail)
+	if q.FutureOnly {
+		args = append(args, "--future-only")
+	}
+	if q.LimitPerCalendar > 0 {
+		flag("limit-per-calendar", strconv.Itoa(q.LimitPerCalendar))
+	}
+	if q.Sort != "start" {
+		flag("sort", q.Sort)
+	}
+	flag("near", q.Near)
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t'\"|&;<>()*?[]\\") {
+			args[i] = strconv.Quote(a)
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// SavedQueries returns the saved queries by name.
+func (m *CalendarManager) SavedQueries() (map[string]SavedQuery, error) {
+	queries := make(map[string]SavedQuery)
+	data, err := os.ReadFile(m.Config.QueriesFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return queries, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &queries); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", m.Config.QueriesFile(), err)
+	}
+	return queries, nil
+}
+
+// SavedQueryNames returns the names of the saved queries, sorted.
+func (m *CalendarManager) SavedQueryNames() ([]string, error) {
+	queries, err := m.SavedQueries()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(queries))
+	for name := range queries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// SaveQuery stores q under name, replacing any query of that name.
+func (m *CalendarManager) SaveQuery(name string, q SavedQuery) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("query name is required")
+	}
+	// Catch bad filters now rather than on every run.
+	if _, err := q.Filter(nil, m.Config, m.Now()); err != nil {
+		return err
+	}
+	queries, err := m.SavedQueries()
+	if err != nil {
+		return err
+	}
+	queries[name] = q
+	return m.saveQueries(queries)
+}
+
+// DeleteQuery removes the saved query name.
+func (m *CalendarManager) DeleteQuery(name string) error {
+	queries, err := m.SavedQueries()
+	if err != nil {
+		return err
+	}
+	if _, ok := queries[name]; !ok {
+		return fmt.Errorf("query %q not found", name)
+	}
+	delete(queries, name)
+	return m.saveQueries(queries)
+}
+
+func (m *CalendarManager) saveQueries(queries map[string]SavedQuery) error {
+	data, err := json.MarshalIndent(queries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := m.Config.EnsureDir(); err != nil {
+		return err
+	}
+	return writeFileAtomic(m.Config.QueriesFile(), data, 0644)
+}
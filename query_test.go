@@ -0,0 +1,23 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+)
+
+func TestChangedSinceUsesConfiguredZone(t *testing.T) {
+	t.Setenv("CALENDAR_TZ", "Pacific/Kiritimati")
+	cfg, err := LoadConfig(t.TempDir())
+	if err != nil {
+		t.Skip(err)
+	}
+	// 02:00 on the 5th in Kiritimati, still the 4th in UTC.
+	events := []Event{{UID: "a", Modified: time.Date(2026, 1, 4, 12, 0, 0, 0, time.UTC)}}
+	got, err := SavedQuery{ChangedSince: "2026-01-05"}.Filter(events, cfg, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Errorf("event modified on the 5th in the configured zone was filtered out")
+	}
+}
@@ -0,0 +1,513 @@
+package calendar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var rruleWeekdays = map[string]string{
+	"MO": "Monday", "TU": "Tuesday", "WE": "Wednesday", "TH": "Thursday",
+	"FR": "Friday", "SA": "Saturday", "SU": "Sunday",
+}
+
+var rruleUnits = map[string][2]string{
+	"SECONDLY": {"secondly", "second"},
+	"MINUTELY": {"minutely", "minute"},
+	"HOURLY":   {"hourly", "hour"},
+	"DAILY":    {"daily", "day"},
+	"WEEKLY":   {"weekly", "week"},
+	"MONTHLY":  {"monthly", "month"},
+	"YEARLY":   {"yearly", "year"},
+}
+
+// parseRRULE splits an RRULE value into its upper-cased parts.
+func parseRRULE(rule string) map[string]string {
+	parts := make(map[string]string)
+	for _, kv := range strings.Split(rule, ";") {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		parts[strings.ToUpper(strings.TrimSpace(k))] = strings.ToUpper(strings.TrimSpace(v))
+	}
+	return parts
+}
+
+// describeRRULE renders an RRULE value as English, e.g. "Repeats weekly on
+// Monday until 2024-06-01". It covers the common FREQ, INTERVAL, BYDAY,
+// BYMONTHDAY, UNTIL and COUNT combinations and returns "" for an empty rule.
+func describeRRULE(rule string) string {
+	if strings.TrimSpace(rule) == "" {
+		return ""
+	}
+	parts := parseRRULE(rule)
+	unit, ok := rruleUnits[parts["FREQ"]]
+	if !ok {
+		return "Repeats (" + rule + ")"
+	}
+
+	var b strings.Builder
+	b.WriteString("Repeats ")
+	interval, _ := strconv.Atoi(parts["INTERVAL"])
+	if interval > 1 {
+		fmt.Fprintf(&b, "every %d %ss", interval, unit[1])
+	} else {
+		b.WriteString(unit[0])
+	}
+
+	if byday := parts["BYDAY"]; byday != "" {
+		var days []string
+		for _, d := range strings.Split(byday, ",") {
+			days = append(days, describeByDay(d))
+		}
+		b.WriteString(" on " + joinEnglish(days))
+	}
+	if bymonthday := parts["BYMONTHDAY"]; bymonthday != "" {
+		b.WriteString(" on day " + joinEnglish(strings.Split(bymonthday, ",")))
+	}
+
+	if until := parts["UNTIL"]; until != "" {
+		if t, ok := parseRRULEDate(until); ok {
+			b.WriteString(" until " + t.Format("2006-01-02"))
+		}
+	} else if count, err := strconv.Atoi(parts["COUNT"]); err == nil && count > 0 {
+		if count == 1 {
+			b.WriteString(", once")
+		} else {
+			fmt.Fprintf(&b, ", %d times", count)
+		}
+	}
+	return b.String()
+}
+
+// describeByDay renders a BYDAY entry such as "MO", "1MO" or "-1FR".
+func describeByDay(d string) string {
+	day := d
+	if len(d) > 2 {
+		day = d[len(d)-2:]
+	}
+	name, ok := rruleWeekdays[day]
+	if !ok {
+		return d
+	}
+	n, err := strconv.Atoi(d[:len(d)-2])
+	if err != nil {
+		return name
+	}
+	ordinals := map[int]string{1: "first", 2: "second", 3: "third", 4: "fourth", 5: "fifth", -1: "last", -2: "second to last"}
+	if o, ok := ordinals[n]; ok {
+		return "the " + o + " " + name
+	}
+	return fmt.Sprintf("the %d. %s", n, name)
+}
+
+// parseRRULEDate parses an UNTIL value in date or date-time form.
+func parseRRULEDate(v string) (time.Time, bool) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// joinEnglish joins items as "a", "a and b", or "a, b and c".
+func joinEnglish(items []string) string {
+	switch len(items) {
+	case 0:
+		return ""
+	case 1:
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
+
+// rruleSupported lists the RRULE parts expandRRULE understands.
+var rruleSupported = map[string]bool{
+	"FREQ": true, "INTERVAL": true, "COUNT": true, "UNTIL": true, "WKST": true,
+	"BYDAY": true, "BYMONTHDAY": true, "BYMONTH": true, "BYSETPOS": true,
+}
+
+var rruleWeekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// maxRRULEPeriods bounds how many periods expandRRULE walks, so a rule with
+// no end can't loop forever.
+const maxRRULEPeriods = 100000
+
+// byDay is a BYDAY entry: a weekday with an optional ordinal such as the
+// -1 in "-1FR" (0 means every such weekday).
+type byDay struct {
+	n  int
+	wd time.Weekday
+}
+
+// rrule is a parsed RRULE ready for expansion.
+type rrule struct {
+	freq       string
+	interval   int
+	count      int
+	until      time.Time
+	wkst       time.Weekday
+	byDay      []byDay
+	byMonthDay []int
+	byMonth    []int
+	bySetPos   []int
+}
+
+// compileRRULE parses rule for expansion. Floating and date-only UNTIL
+// values are read in loc. It returns false for rules using parts or
+// frequencies expandRRULE doesn't support.
+func compileRRULE(rule string, loc *time.Location) (*rrule, bool) {
+	parts := parseRRULE(rule)
+	for k := range parts {
+		if !rruleSupported[k] {
+			return nil, false
+		}
+	}
+	r := &rrule{freq: parts["FREQ"], interval: 1, wkst: time.Monday}
+	switch r.freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	default:
+		return nil, false
+	}
+
+	var ok bool
+	if v, set := parts["INTERVAL"]; set {
+		if r.interval, ok = parsePositive(v); !ok {
+			return nil, false
+		}
+	}
+	if v, set := parts["COUNT"]; set {
+		if r.count, ok = parsePositive(v); !ok {
+			return nil, false
+		}
+	}
+	if v, set := parts["UNTIL"]; set {
+		if r.until, ok = parseRRULEUntil(v, loc); !ok {
+			return nil, false
+		}
+	}
+	if v, set := parts["WKST"]; set {
+		if r.wkst, ok = rruleWeekdayCodes[v]; !ok {
+			return nil, false
+		}
+	}
+	if r.byDay, ok = parseByDay(parts["BYDAY"]); !ok {
+		return nil, false
+	}
+	if r.byMonthDay, ok = parseIntList(parts["BYMONTHDAY"], 31); !ok {
+		return nil, false
+	}
+	if r.byMonth, ok = parseIntList(parts["BYMONTH"], 12); !ok {
+		return nil, false
+	}
+	if r.bySetPos, ok = parseIntList(parts["BYSETPOS"], 366); !ok {
+		return nil, false
+	}
+	for _, m := range r.byMonth {
+		if m < 1 {
+			return nil, false
+		}
+	}
+
+	// Ordinal weekdays only make sense within a month or year, and
+	// BYMONTHDAY has no meaning for weekly rules.
+	if r.freq == "DAILY" || r.freq == "WEEKLY" {
+		for _, bd := range r.byDay {
+			if bd.n != 0 {
+				return nil, false
+			}
+		}
+	}
+	if r.freq == "WEEKLY" && len(r.byMonthDay) > 0 {
+		return nil, false
+	}
+	return r, true
+}
+
+// expandRRULE returns the starts of rule's occurrences, anchored at dtstart,
+// that fall in [from, to); a zero from leaves the start open, but to must be
+// set. Occurrences keep dtstart's wall-clock time in its location, so they
+// stay put across DST changes. DTSTART itself is always the first
+// occurrence. It returns false when the rule uses parts it doesn't support,
+// so callers can fall back to the base event.
+func expandRRULE(rule string, dtstart, from, to time.Time) ([]time.Time, bool) {
+	r, ok := compileRRULE(rule, dtstart.Location())
+	if !ok {
+		return nil, false
+	}
+
+	var out []time.Time
+	seen := 1
+	if (from.IsZero() || !dtstart.Before(from)) && dtstart.Before(to) {
+		out = append(out, dtstart)
+	}
+
+	for i := 0; i < maxRRULEPeriods; i++ {
+		for _, c := range r.setPos(r.period(dtstart, i)) {
+			if !c.After(dtstart) {
+				continue
+			}
+			if !r.until.IsZero() && c.After(r.until) {
+				return out, true
+			}
+			seen++
+			if r.count > 0 && seen > r.count {
+				return out, true
+			}
+			if !c.Before(to) {
+				return out, true
+			}
+			if from.IsZero() || !c.Before(from) {
+				out = append(out, c)
+			}
+		}
+	}
+	return out, true
+}
+
+// period returns the sorted candidate occurrences in the i-th period
+// (day, week, month or year, stepped by INTERVAL) after dtstart's.
+func (r *rrule) period(dtstart time.Time, i int) []time.Time {
+	y, mo, d := dtstart.Date()
+	h, mi, s := dtstart.Clock()
+	loc := dtstart.Location()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, h, mi, s, 0, loc)
+	}
+	step := i * r.interval
+
+	var days []time.Time
+	switch r.freq {
+	case "DAILY":
+		day := at(y, mo, d+step)
+		if r.matchesMonth(day.Month()) && r.matchesMonthDay(day) && r.matchesWeekday(day.Weekday()) {
+			days = append(days, day)
+		}
+	case "WEEKLY":
+		if len(r.byDay) == 0 {
+			if day := at(y, mo, d+7*step); r.matchesMonth(day.Month()) {
+				days = append(days, day)
+			}
+			break
+		}
+		back := (int(dtstart.Weekday()) - int(r.wkst) + 7) % 7
+		for j := 0; j < 7; j++ {
+			day := at(y, mo, d-back+7*step+j)
+			if r.matchesMonth(day.Month()) && r.matchesWeekday(day.Weekday()) {
+				days = append(days, day)
+			}
+		}
+	case "MONTHLY":
+		first := time.Date(y, mo+time.Month(step), 1, 0, 0, 0, 0, loc)
+		if r.matchesMonth(first.Month()) {
+			days = r.monthDays(first.Year(), first.Month(), d, at)
+		}
+	case "YEARLY":
+		year := y + step
+		switch {
+		case len(r.byMonth) > 0:
+			for m := time.January; m <= time.December; m++ {
+				if r.matchesMonth(m) {
+					days = append(days, r.monthDays(year, m, d, at)...)
+				}
+			}
+		case len(r.byMonthDay) > 0:
+			for m := time.January; m <= time.December; m++ {
+				days = append(days, r.monthDays(year, m, d, at)...)
+			}
+		case len(r.byDay) > 0:
+			days = r.yearDays(year, at)
+		default:
+			if day := at(year, mo, d); day.Month() == mo {
+				days = append(days, day)
+			}
+		}
+	}
+	return days
+}
+
+// monthDays returns the candidates in one month: the BYMONTHDAY days,
+// narrowed by BYDAY when both are set, or the BYDAY days, or else dtstart's
+// day of month if the month has it.
+func (r *rrule) monthDays(year int, m time.Month, dtDay int, at func(int, time.Month, int) time.Time) []time.Time {
+	last := daysIn(year, m)
+	var days []time.Time
+	for day := 1; day <= last; day++ {
+		t := at(year, m, day)
+		switch {
+		case len(r.byMonthDay) > 0:
+			if !r.matchesMonthDay(t) || (len(r.byDay) > 0 && !r.matchesByDay(t.Weekday(), day, last)) {
+				continue
+			}
+		case len(r.byDay) > 0:
+			if !r.matchesByDay(t.Weekday(), day, last) {
+				continue
+			}
+		default:
+			if day != dtDay {
+				continue
+			}
+		}
+		days = append(days, t)
+	}
+	return days
+}
+
+// yearDays returns the days of year matching BYDAY, with ordinals counted
+// within the year.
+func (r *rrule) yearDays(year int, at func(int, time.Month, int) time.Time) []time.Time {
+	last := time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+	var days []time.Time
+	for yd := 1; yd <= last; yd++ {
+		t := at(year, time.January, yd)
+		if r.matchesByDay(t.Weekday(), yd, last) {
+			days = append(days, t)
+		}
+	}
+	return days
+}
+
+// setPos applies BYSETPOS to a period's sorted candidates.
+func (r *rrule) setPos(days []time.Time) []time.Time {
+	if len(r.bySetPos) == 0 {
+		return days
+	}
+	var picked []time.Time
+	for i, t := range days {
+		for _, p := range r.bySetPos {
+			if p == i+1 || p == i-len(days) {
+				picked = append(picked, t)
+				break
+			}
+		}
+	}
+	return picked
+}
+
+func (r *rrule) matchesMonth(m time.Month) bool {
+	if len(r.byMonth) == 0 {
+		return true
+	}
+	for _, bm := range r.byMonth {
+		if time.Month(bm) == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rrule) matchesMonthDay(t time.Time) bool {
+	if len(r.byMonthDay) == 0 {
+		return true
+	}
+	last := daysIn(t.Year(), t.Month())
+	for _, md := range r.byMonthDay {
+		if md == t.Day() || (md < 0 && last+md+1 == t.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesWeekday reports whether wd is listed in BYDAY, ignoring ordinals.
+func (r *rrule) matchesWeekday(wd time.Weekday) bool {
+	return r.matchesByDay(wd, 0, 0)
+}
+
+// matchesByDay reports whether wd, as the index-th of last days in its month
+// or year, matches a BYDAY entry. An index of 0 ignores ordinals.
+func (r *rrule) matchesByDay(wd time.Weekday, index, last int) bool {
+	if len(r.byDay) == 0 {
+		return true
+	}
+	for _, bd := range r.byDay {
+		if bd.wd != wd {
+			continue
+		}
+		switch {
+		case bd.n == 0 || index == 0:
+			return true
+		case bd.n > 0 && (index-1)/7+1 == bd.n:
+			return true
+		case bd.n < 0 && (last-index)/7+1 == -bd.n:
+			return true
+		}
+	}
+	return false
+}
+
+// daysIn returns the number of days in month m of year.
+func daysIn(year int, m time.Month) int {
+	return time.Date(year, m+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// parseByDay parses a BYDAY list such as "MO,WE" or "1MO,-1FR".
+func parseByDay(v string) ([]byDay, bool) {
+	if v == "" {
+		return nil, true
+	}
+	var days []byDay
+	for _, d := range strings.Split(v, ",") {
+		if len(d) < 2 {
+			return nil, false
+		}
+		wd, ok := rruleWeekdayCodes[d[len(d)-2:]]
+		if !ok {
+			return nil, false
+		}
+		bd := byDay{wd: wd}
+		if prefix := d[:len(d)-2]; prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, false
+			}
+			bd.n = n
+		}
+		days = append(days, bd)
+	}
+	return days, true
+}
+
+// parseIntList parses a comma-separated list of non-zero integers within
+// [-max, max].
+func parseIntList(v string, max int) ([]int, bool) {
+	if v == "" {
+		return nil, true
+	}
+	var nums []int
+	for _, s := range strings.Split(v, ",") {
+		n, err := strconv.Atoi(s)
+		if err != nil || n == 0 || n < -max || n > max {
+			return nil, false
+		}
+		nums = append(nums, n)
+	}
+	return nums, true
+}
+
+// parsePositive parses a positive integer rule part.
+func parsePositive(v string) (int, bool) {
+	n, err := strconv.Atoi(v)
+	return n, err == nil && n > 0
+}
+
+// parseRRULEUntil parses UNTIL for expansion. A UTC value is absolute, a
+// floating one is read in loc, and a bare date includes that whole day.
+func parseRRULEUntil(v string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse("20060102T150405Z", v); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation("20060102T150405", v, loc); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation("20060102", v, loc); err == nil {
+		return t.AddDate(0, 0, 1).Add(-time.Second), true
+	}
+	return time.Time{}, false
+}
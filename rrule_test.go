@@ -0,0 +1,176 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// dates parses a list of YYYY-MM-DD days at 09:00 UTC.
+func dates(t *testing.T, days ...string) []time.Time {
+	t.Helper()
+	out := make([]time.Time, len(days))
+	for i, d := range days {
+		day, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out[i] = day.Add(9 * time.Hour)
+	}
+	return out
+}
+
+func TestExpandRRULE(t *testing.T) {
+	to := time.Date(2040, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		rule    string
+		dtstart string
+		from    string
+		want    []string
+	}{
+		{"FREQ=DAILY;INTERVAL=3;COUNT=4", "2026-01-05", "",
+			[]string{"2026-01-05", "2026-01-08", "2026-01-11", "2026-01-14"}},
+		{"FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=6", "2026-01-05", "",
+			[]string{"2026-01-05", "2026-01-07", "2026-01-09", "2026-01-12", "2026-01-14", "2026-01-16"}},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH;COUNT=4", "2026-01-06", "",
+			[]string{"2026-01-06", "2026-01-08", "2026-01-20", "2026-01-22"}},
+		{"FREQ=WEEKLY;BYDAY=MO;UNTIL=20260126T090000Z", "2026-01-05", "",
+			[]string{"2026-01-05", "2026-01-12", "2026-01-19", "2026-01-26"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=15;COUNT=3", "2026-01-15", "",
+			[]string{"2026-01-15", "2026-02-15", "2026-03-15"}},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3", "2026-01-31", "",
+			[]string{"2026-01-31", "2026-02-28", "2026-03-31"}},
+		{"FREQ=MONTHLY;BYDAY=2TU;COUNT=3", "2026-01-13", "",
+			[]string{"2026-01-13", "2026-02-10", "2026-03-10"}},
+		{"FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1;COUNT=3", "2026-01-30", "",
+			[]string{"2026-01-30", "2026-02-27", "2026-03-31"}},
+		{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=3", "2026-02-13", "",
+			[]string{"2026-02-13", "2026-03-13", "2026-11-13"}},
+		// Months without a 31st are skipped, not clamped.
+		{"FREQ=MONTHLY;INTERVAL=3;COUNT=3", "2026-01-31", "",
+			[]string{"2026-01-31", "2026-07-31", "2026-10-31"}},
+		{"FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU;COUNT=3", "2026-03-29", "",
+			[]string{"2026-03-29", "2027-03-28", "2028-03-26"}},
+		{"FREQ=YEARLY;BYMONTH=1,7;BYMONTHDAY=1;COUNT=4", "2026-01-01", "",
+			[]string{"2026-01-01", "2026-07-01", "2027-01-01", "2027-07-01"}},
+		{"FREQ=YEARLY;INTERVAL=2;COUNT=3", "2024-02-29", "",
+			[]string{"2024-02-29", "2028-02-29", "2032-02-29"}},
+		// COUNT includes the occurrences before from.
+		{"FREQ=WEEKLY;BYDAY=MO;COUNT=4", "2026-01-05", "2026-01-15",
+			[]string{"2026-01-19", "2026-01-26"}},
+	} {
+		t.Run(tc.rule, func(t *testing.T) {
+			dtstart := dates(t, tc.dtstart)[0]
+			var from time.Time
+			if tc.from != "" {
+				from = dates(t, tc.from)[0]
+			}
+			got, ok := expandRRULE(tc.rule, dtstart, from, to)
+			if !ok {
+				t.Fatal("rule not supported")
+			}
+			want := dates(t, tc.want...)
+			if len(got) != len(want) {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			for i := range want {
+				if !got[i].Equal(want[i]) {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExpandRRULEUnsupported(t *testing.T) {
+	dtstart := dates(t, "2026-01-05")[0]
+	to := dtstart.AddDate(1, 0, 0)
+	for _, rule := range []string{
+		"FREQ=HOURLY",
+		"FREQ=WEEKLY;BYWEEKNO=3",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=WEEKLY;BYMONTHDAY=5",
+		"FREQ=MONTHLY;BYMONTH=13",
+	} {
+		if _, ok := expandRRULE(rule, dtstart, time.Time{}, to); ok {
+			t.Errorf("%s: expanded, want it reported unsupported", rule)
+		}
+	}
+}
+
+const recurringFeed = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+	"BEGIN:VEVENT\r\nUID:weekly\r\nDTSTAMP:20260101T000000Z\r\nDTSTART:20260105T090000Z\r\nDTEND:20260105T100000Z\r\nRRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=6\r\nSUMMARY:Gym\r\nEND:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\nUID:fallback\r\nDTSTAMP:20260101T000000Z\r\nDTSTART:20260105T120000Z\r\nRRULE:FREQ=WEEKLY;BYWEEKNO=3\r\nSUMMARY:Odd\r\nEND:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestRecurringEventsListAndExport(t *testing.T) {
+	m := newTestManager(t)
+	syncFeed(t, m, recurringFeed)
+	events, err := m.ListEvents(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := map[string]int{}
+	for _, e := range events {
+		count[e.UID]++
+	}
+	if count["weekly"] != 6 {
+		t.Errorf("listed %d instances of the weekly event, want 6", count["weekly"])
+	}
+	// An unsupported rule shows just the base event.
+	if count["fallback"] != 1 {
+		t.Errorf("listed %d instances of the unsupported rule, want 1", count["fallback"])
+	}
+
+	// Exporting the instances writes the master once, not once per
+	// instance.
+	data, err := m.ExportICS(events, ExportOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(data, "UID:weekly\r\n"); n != 1 {
+		t.Errorf("export has %d VEVENTs for the weekly event, want 1", n)
+	}
+	for _, ie := range decodeExport(t, data) {
+		if uid, _ := ie.Props.Text("UID"); uid == "weekly" {
+			if rule := ie.Props.Get("RRULE"); rule == nil || rule.Value != "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=6" {
+				t.Errorf("exported master lost its RRULE: %v", rule)
+			}
+		}
+	}
+}
+
+func TestExpandDailyAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	// DST starts on Sunday 2026-03-08, the second Sunday of March.
+	feed := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+		"BEGIN:VEVENT\r\nUID:daily\r\nDTSTAMP:20260101T000000Z\r\nDTSTART;TZID=America/New_York:20260305T090000\r\n" +
+		"DTEND;TZID=America/New_York:20260305T093000\r\nRRULE:FREQ=DAILY\r\nSUMMARY:Standup\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	m := newTestManager(t)
+	syncFeed(t, m, feed)
+	from := time.Date(2026, 3, 5, 0, 0, 0, 0, ny)
+	events, err := m.ListEvents(from, from.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 7 {
+		t.Fatalf("got %d instances, want 7", len(events))
+	}
+	for _, e := range events {
+		start := e.Start.In(ny)
+		if start.Hour() != 9 || start.Minute() != 0 {
+			t.Errorf("instance on %s starts at %s, want 09:00", start.Format("2006-01-02"), start.Format("15:04"))
+		}
+		if d := e.End.Sub(e.Start); d != 30*time.Minute {
+			t.Errorf("instance on %s lasts %s, want 30m", start.Format("2006-01-02"), d)
+		}
+	}
+	// The UTC offset changes across the switch, the wall clock doesn't.
+	if before, after := events[2].Start.UTC().Hour(), events[3].Start.UTC().Hour(); before != 14 || after != 13 {
+		t.Errorf("UTC hours around the switch = %d, %d; want 14, 13", before, after)
+	}
+}
@@ -0,0 +1,306 @@
+package calendar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Store persists calendar sources and their events.
+type Store interface {
+	// LoadSources returns the configured calendar sources.
+	LoadSources() ([]Source, error)
+	// SaveSources replaces the configured calendar sources.
+	SaveSources(sources []Source) error
+	// SaveEvent stores the raw iCal data for a single event.
+	SaveEvent(calName, uid string, data []byte) error
+	// LoadEvents returns the raw iCal data of every event in a calendar.
+	// A calendar that has never been synced has no events, not an error.
+	LoadEvents(calName string) ([][]byte, error)
+	// WalkEvents calls fn with the raw iCal data of each event in a
+	// calendar, one at a time, stopping at the first error fn returns.
+	WalkEvents(calName string, fn func(data []byte) error) error
+	// DeleteEvent removes a single stored event.
+	DeleteEvent(calName, uid string) error
+	// DeleteCalendar removes all stored events for a calendar.
+	DeleteCalendar(calName string) error
+	// LoadMeta returns a calendar's sync metadata, or the zero value if it
+	// has never been synced.
+	LoadMeta(calName string) (SyncMeta, error)
+	// SaveMeta records a calendar's sync metadata.
+	SaveMeta(calName string, meta SyncMeta) error
+	// BackupSources snapshots the current sources before a destructive change.
+	BackupSources() error
+	// RestoreSources replaces the sources with the most recent snapshot and
+	// discards that snapshot.
+	RestoreSources() error
+}
+
+// EventQuarantine is implemented by stores that can set aside stored events
+// that no longer parse, so a sync doesn't leave them in place forever.
+type EventQuarantine interface {
+	// QuarantineEvents moves every stored event of calName for which bad
+	// returns true out of the calendar, returning where each one went.
+	QuarantineEvents(calName string, bad func(data []byte) bool) ([]string, error)
+}
+
+// quarantineDir is where FSStore moves unreadable events, inside the
+// calendar's directory so removing the calendar removes them too.
+const quarantineDir = ".quarantine"
+
+// FSStore is a Store backed by the directory layout described by Config.
+type FSStore struct {
+	Config *Config
+}
+
+// NewFSStore creates a filesystem store rooted at cfg.Dir.
+func NewFSStore(cfg *Config) *FSStore {
+	return &FSStore{Config: cfg}
+}
+
+// LoadSources reads sources.json, returning nil if it doesn't exist yet.
+func (s *FSStore) LoadSources() ([]Source, error) {
+	data, err := os.ReadFile(s.Config.SourcesFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var sources []Source
+	if err := json.Unmarshal(data, &sources); err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// SaveSources writes sources.json.
+func (s *FSStore) SaveSources(sources []Source) error {
+	data, err := json.MarshalIndent(sources, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.Config.SourcesFile(), data, 0644)
+}
+
+// ErrUnsafeUID is returned when an event's UID doesn't map to a file
+// directly inside its calendar's directory.
+var ErrUnsafeUID = errors.New("UID would be stored outside the calendar directory")
+
+// eventPath returns the path of uid's .ics file, checking that it stays
+// directly inside the calendar's directory.
+func (s *FSStore) eventPath(calName, uid string) (string, error) {
+	dir := filepath.Clean(s.Config.CalendarDir(calName))
+	path := filepath.Clean(filepath.Join(dir, sanitizeFilename(uid)+".ics"))
+	if filepath.Dir(path) != dir || filepath.Base(path) == ".ics" {
+		return "", fmt.Errorf("%w: %q", ErrUnsafeUID, uid)
+	}
+	return path, nil
+}
+
+// SaveEvent writes an event to <uid>.ics in the calendar's directory.
+func (s *FSStore) SaveEvent(calName, uid string, data []byte) error {
+	path, err := s.eventPath(calName, uid)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// LoadEvents reads every .ics file in the calendar's directory. A missing
+// directory yields no events.
+func (s *FSStore) LoadEvents(calName string) ([][]byte, error) {
+	var events [][]byte
+	err := s.WalkEvents(calName, func(data []byte) error {
+		events = append(events, data)
+		return nil
+	})
+	return events, err
+}
+
+// WalkEvents reads the .ics files in the calendar's directory one at a
+// time, skipping unreadable ones. A missing directory yields no events.
+func (s *FSStore) WalkEvents(calName string, fn func(data []byte) error) error {
+	dir := s.Config.CalendarDir(calName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".ics") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		if err := fn(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteEvent removes the event's .ics file.
+func (s *FSStore) DeleteEvent(calName, uid string) error {
+	path, err := s.eventPath(calName, uid)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// DeleteCalendar removes the calendar's event directory.
+func (s *FSStore) DeleteCalendar(calName string) error {
+	return os.RemoveAll(s.Config.CalendarDir(calName))
+}
+
+// QuarantineEvents moves the calendar's bad .ics files into its
+// .quarantine directory, which event reads don't look in.
+func (s *FSStore) QuarantineEvents(calName string, bad func(data []byte) bool) ([]string, error) {
+	dir := s.Config.CalendarDir(calName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var moved []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".ics") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil || !bad(data) {
+			continue
+		}
+		dest := filepath.Join(dir, quarantineDir, entry.Name())
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return moved, err
+		}
+		if err := os.Rename(path, dest); err != nil {
+			return moved, err
+		}
+		moved = append(moved, dest)
+	}
+	return moved, nil
+}
+
+// LoadMeta reads meta.json from the calendar's directory.
+func (s *FSStore) LoadMeta(calName string) (SyncMeta, error) {
+	var meta SyncMeta
+	data, err := os.ReadFile(s.metaFile(calName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return meta, nil
+		}
+		return meta, err
+	}
+	err = json.Unmarshal(data, &meta)
+	return meta, err
+}
+
+// SaveMeta writes meta.json to the calendar's directory.
+func (s *FSStore) SaveMeta(calName string, meta SyncMeta) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	dir := s.Config.CalendarDir(calName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.metaFile(calName), data, 0644)
+}
+
+// BackupSources copies sources.json to a timestamped backup in Config.Dir,
+// keeping only the newest Config.MaxBackups copies.
+func (s *FSStore) BackupSources() error {
+	data, err := os.ReadFile(s.Config.SourcesFile())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	name := s.Config.SourcesFile() + "." + time.Now().UTC().Format("20060102T150405.000000000") + ".bak"
+	if err := writeFileAtomic(name, data, 0644); err != nil {
+		return err
+	}
+
+	backups, err := s.sourceBackups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > s.Config.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// RestoreSources moves the newest backup back to sources.json.
+func (s *FSStore) RestoreSources() error {
+	backups, err := s.sourceBackups()
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 {
+		return fmt.Errorf("no backups found in %s", s.Config.Dir)
+	}
+	return os.Rename(backups[len(backups)-1], s.Config.SourcesFile())
+}
+
+// sourceBackups returns the sources.json backups, oldest first.
+func (s *FSStore) sourceBackups() ([]string, error) {
+	backups, err := filepath.Glob(s.Config.SourcesFile() + ".*.bak")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func (s *FSStore) metaFile(calName string) string {
+	return filepath.Join(s.Config.CalendarDir(calName), "meta.json")
+}
+
+// writeFileAtomic writes data to a temp file next to path and renames it
+// into place, so readers never observe a partially written file.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
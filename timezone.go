@@ -0,0 +1,144 @@
+package calendar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	ical "github.com/emersion/go-ical"
+)
+
+// tzOffsetWarnings compares each event's DTSTART offset as resolved from
+// the IANA database with the offset the feed's own VTIMEZONE of the same
+// TZID gives, and describes every mismatch. A mismatch usually means the
+// feed embeds a stale zone definition; the IANA zone is still the one used.
+func tzOffsetWarnings(cal *ical.Calendar) []string {
+	timezones := make(map[string]*ical.Component)
+	for _, child := range cal.Children {
+		if child.Name != ical.CompTimezone {
+			continue
+		}
+		if tzid, err := child.Props.Text(ical.PropTimezoneID); err == nil && tzid != "" {
+			timezones[tzid] = child
+		}
+	}
+	if len(timezones) == 0 {
+		return nil
+	}
+
+	var warnings []string
+	for _, event := range cal.Events() {
+		p := event.Props.Get(ical.PropDateTimeStart)
+		if p == nil || strings.HasSuffix(p.Value, "Z") {
+			continue
+		}
+		tzid, _ := paramValue(p, ical.ParamTimezoneID)
+		tz, ok := timezones[tzid]
+		if !ok {
+			continue
+		}
+		loc, err := time.LoadLocation(tzid)
+		if err != nil {
+			continue
+		}
+		t, allDay := parsePropTime(p, loc)
+		if t.IsZero() || allDay {
+			continue
+		}
+		want, ok := vtimezoneOffset(tz, t)
+		if !ok {
+			continue
+		}
+		if _, got := t.Zone(); got != want {
+			uid, _ := event.Props.Text(ical.PropUID)
+			warnings = append(warnings, fmt.Sprintf("%s: DTSTART %s in %s is UTC%s, but the feed's VTIMEZONE says UTC%s",
+				uid, p.Value, tzid, formatUTCOffset(got), formatUTCOffset(want)))
+		}
+	}
+	return warnings
+}
+
+// vtimezoneOffset returns the UTC offset in seconds that the VTIMEZONE tz
+// gives for the wall-clock time of t: the TZOFFSETTO of the STANDARD or
+// DAYLIGHT observance with the latest onset at or before it.
+func vtimezoneOffset(tz *ical.Component, t time.Time) (int, bool) {
+	// Onsets are local times, so compare everything as naive UTC wall times.
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	var latest time.Time
+	offset, found := 0, false
+	for _, obs := range tz.Children {
+		if obs.Name != ical.CompTimezoneStandard && obs.Name != ical.CompTimezoneDaylight {
+			continue
+		}
+		to := obs.Props.Get(ical.PropTimezoneOffsetTo)
+		if to == nil {
+			continue
+		}
+		off, err := parseUTCOffset(to.Value)
+		if err != nil {
+			continue
+		}
+		start := obs.Props.Get(ical.PropDateTimeStart)
+		if start == nil {
+			continue
+		}
+		dtstart, err := time.ParseInLocation("20060102T150405", start.Value, time.UTC)
+		if err != nil {
+			continue
+		}
+
+		onsets := []time.Time{dtstart}
+		if rule := obs.Props.Get(ical.PropRecurrenceRule); rule != nil {
+			if starts, ok := expandRRULE(rule.Value, dtstart, time.Time{}, wall.Add(time.Second)); ok {
+				onsets = starts
+			}
+		}
+		for _, p := range obs.Props.Values(ical.PropRecurrenceDates) {
+			for _, v := range strings.Split(p.Value, ",") {
+				if d, err := time.ParseInLocation("20060102T150405", strings.TrimSpace(v), time.UTC); err == nil {
+					onsets = append(onsets, d)
+				}
+			}
+		}
+		for _, onset := range onsets {
+			if onset.After(wall) || (found && !onset.After(latest)) {
+				continue
+			}
+			latest, offset, found = onset, off, true
+		}
+	}
+	return offset, found
+}
+
+// parseUTCOffset parses a UTC-OFFSET value such as "-0500" or "+053000"
+// into seconds east of UTC.
+func parseUTCOffset(s string) (int, error) {
+	if len(s) != 5 && len(s) != 7 || (s[0] != '+' && s[0] != '-') {
+		return 0, fmt.Errorf("invalid UTC offset %q", s)
+	}
+	secs := 0
+	for i, unit := range []int{3600, 60, 1} {
+		if 1+2*i >= len(s) {
+			break
+		}
+		n, err := strconv.Atoi(s[1+2*i : 3+2*i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid UTC offset %q", s)
+		}
+		secs += n * unit
+	}
+	if s[0] == '-' {
+		secs = -secs
+	}
+	return secs, nil
+}
+
+// formatUTCOffset formats seconds east of UTC as "+05:30".
+func formatUTCOffset(secs int) string {
+	sign := '+'
+	if secs < 0 {
+		sign, secs = '-', -secs
+	}
+	return fmt.Sprintf("%c%02d:%02d", sign, secs/3600, secs%3600/60)
+}
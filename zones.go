@@ -0,0 +1,171 @@
+package calendar
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// zoneinfoDirs are where time.LoadLocation looks for the zoneinfo database
+// on Unix systems, in its order.
+var zoneinfoDirs = []string{
+	"/usr/share/zoneinfo/",
+	"/usr/share/lib/zoneinfo/",
+	"/usr/lib/locale/TZ/",
+	"/etc/zoneinfo/",
+}
+
+// TimeZones returns the sorted IANA zone names in the zoneinfo database that
+// contain filter, ignoring case. It reads $ZONEINFO if set, then the system
+// database.
+func TimeZones(filter string) ([]string, error) {
+	names, err := zoneNames()
+	if err != nil {
+		return nil, err
+	}
+	filter = strings.ToLower(filter)
+	var matched []string
+	for _, name := range names {
+		if strings.Contains(strings.ToLower(name), filter) {
+			matched = append(matched, name)
+		}
+	}
+	return matched, nil
+}
+
+// LoadTimeZone loads an IANA zone like time.LoadLocation, but on failure
+// suggests zones whose name matches ignoring case or whose city is a typo
+// or two away from the one given.
+func LoadTimeZone(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err == nil {
+		return loc, nil
+	}
+	var suggestions []string
+	if names, zerr := zoneNames(); zerr == nil {
+		city := strings.ToLower(name[strings.LastIndex(name, "/")+1:])
+		// Allow fewer typos in short names, which match too much otherwise.
+		maxTypos := min(len(city)/3, 2)
+		for _, n := range names {
+			lower := strings.ToLower(n)
+			if lower == strings.ToLower(name) || (maxTypos > 0 && editDistance(lower[strings.LastIndex(lower, "/")+1:], city) <= maxTypos) {
+				suggestions = append(suggestions, n)
+			}
+		}
+	}
+	if len(suggestions) > 0 {
+		return nil, fmt.Errorf("unknown time zone %q (did you mean %s?)", name, strings.Join(suggestions, ", "))
+	}
+	return nil, fmt.Errorf("unknown time zone %q (see tz list)", name)
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// zoneNames lists every zone in the first zoneinfo database found. The
+// copy embedded by time/tzdata can't be listed, so it isn't consulted.
+func zoneNames() ([]string, error) {
+	dirs := zoneinfoDirs
+	if env := os.Getenv("ZONEINFO"); env != "" {
+		if info, err := os.Stat(env); err == nil && !info.IsDir() {
+			return zipZoneNames(env)
+		}
+		dirs = append([]string{env}, dirs...)
+	}
+	for _, dir := range dirs {
+		names, err := dirZoneNames(dir)
+		if err == nil && len(names) > 0 {
+			return names, nil
+		}
+	}
+	return nil, errors.New("no zoneinfo database found (set $ZONEINFO to one)")
+}
+
+// dirZoneNames walks a zoneinfo directory for TZif files, skipping the
+// posix/ and right/ copies and aliases for the local zone.
+func dirZoneNames(dir string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if d.IsDir() {
+			if rel == "posix" || rel == "right" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !validZoneName(rel) || !isTZif(path) {
+			return nil
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// zipZoneNames lists the zones in a zoneinfo.zip like the one Go ships.
+func zipZoneNames(path string) ([]string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("no zoneinfo database found: %w", err)
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, "/") && validZoneName(f.Name) {
+			names = append(names, f.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func validZoneName(name string) bool {
+	switch name {
+	case "localtime", "posixrules", "Factory":
+		return false
+	}
+	return !strings.Contains(name, ".")
+}
+
+// isTZif reports whether the file at path starts with the TZif magic.
+func isTZif(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, 4)
+	if _, err := f.Read(magic); err != nil {
+		return false
+	}
+	return bytes.Equal(magic, []byte("TZif"))
+}